@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/builder"
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/gobuilder/pkg/gobuilder"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+const buildUsage = `Usage: gber build [gber flags] -- [go build args]
+
+Flags:
+  --profile <name>       build a profile of build.json
+  --all                  build all the profiles
+  --target <os/arch>     build the target for this run, repeatable
+  --targets <patterns>   select targets like 'linux/*,!linux/mips*' for this run
+  --jobs <n>             build n targets at the same time
+  --keep-going           keep building the other targets after one fails
+  --force                build without the build cache
+  --output text|json     print the events as newline-delimited JSON on stdout
+  --dry-run              print the commands without building
+  --explain              like --dry-run, and say why the args are added or rewritten
+  --replace-args         the go build args replace the saved build_args, instead of being merged
+  --save                 save the go build args to build.json
+  -h, --help             show this help
+
+The go build args are merged into the saved build_args for this run: a flag replaces the same flag,
+and the packages replace the saved packages. Use #(...) for command substitutions like $(...).
+
+Example: gber build --profile server --targets 'linux/*,!linux/mips*' --jobs 4 -- -tags netgo ./cmd/server
+`
+
+type buildFlags struct {
+	jobs      int
+	keepGoing bool
+	force     bool
+	output    string
+	dryRun    bool
+	explain   bool
+	targets   []string // patterns like "linux/*" or "!linux/mips*".
+	replace   bool     // the go build args replace the saved ones instead of being merged.
+	save      bool
+	help      bool
+	profile   string
+	all       bool
+}
+
+/*
+parseBuildFlags parses "gber build [gber flags] -- [go build args]".
+*/
+func parseBuildFlags(args []string) (flags *buildFlags, goArgs []string, err error) {
+	flags = &buildFlags{output: outputText}
+	goArgs = []string{}
+	// value of a flag like "--jobs 4" or "--jobs=4".
+	value := func(idx *int, name string) (string, error) {
+		arg := args[*idx]
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"="), nil
+		}
+		if *idx+1 >= len(args) {
+			return "", fmt.Errorf("flag needs an argument: %s", name)
+		}
+		*idx++
+		return args[*idx], nil
+	}
+	isFlag := func(arg, name string) bool {
+		return arg == name || strings.HasPrefix(arg, name+"=")
+	}
+
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		switch {
+		case arg == "--":
+			// the rest is for go build.
+			goArgs = append(goArgs, args[idx+1:]...)
+			idx = len(args)
+		case arg == "-h" || arg == "--help":
+			flags.help = true
+		case arg == "--keep-going":
+			flags.keepGoing = true
+		case arg == "--force":
+			flags.force = true
+		case arg == "--dry-run":
+			flags.dryRun = true
+		case arg == "--explain":
+			// explain is a dry run.
+			flags.dryRun, flags.explain = true, true
+		case arg == "--replace-args":
+			flags.replace = true
+		case arg == "--save":
+			flags.save = true
+		case arg == "--all":
+			flags.all = true
+		case isFlag(arg, "--profile"):
+			if flags.profile, err = value(&idx, "--profile"); err != nil {
+				return nil, nil, err
+			}
+		case isFlag(arg, "--output"):
+			if flags.output, err = value(&idx, "--output"); err != nil {
+				return nil, nil, err
+			}
+			if flags.output != outputText && flags.output != outputJSON {
+				return nil, nil, fmt.Errorf("invalid value %q for --output, expected text or json", flags.output)
+			}
+		case isFlag(arg, "--targets"):
+			v, err := value(&idx, "--targets")
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					flags.targets = append(flags.targets, t)
+				}
+			}
+		case isFlag(arg, "--target"):
+			v, err := value(&idx, "--target")
+			if err != nil {
+				return nil, nil, err
+			}
+			flags.targets = append(flags.targets, v)
+		case isFlag(arg, "--jobs"):
+			v, err := value(&idx, "--jobs")
+			if err != nil {
+				return nil, nil, err
+			}
+			if flags.jobs, err = strconv.Atoi(v); err != nil || flags.jobs < 1 {
+				return nil, nil, fmt.Errorf("invalid value %q for --jobs", v)
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown flag %q, put the go build args after --", arg)
+		}
+	}
+	if flags.all && flags.profile != "" {
+		return nil, nil, fmt.Errorf("--all and --profile cannot be used together")
+	}
+	if (flags.save || flags.replace) && len(goArgs) == 0 {
+		return nil, nil, fmt.Errorf("--save and --replace-args need the go build args after --")
+	}
+	if flags.save && flags.dryRun {
+		return nil, nil, fmt.Errorf("--save cannot be used with --dry-run")
+	}
+	return
+}
+
+/*
+runBuild builds the chosen profiles, and exits with code 1 if anything failed.
+*/
+func runBuild(args []string) {
+	flags, goArgs, err := parseBuildFlags(args)
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	if flags.help {
+		fmt.Print(buildUsage)
+		return
+	}
+	var (
+		out     io.Writer = os.Stdout // of the messages for humans.
+		jsonOut io.Writer
+		events  gobuilder.EventHandler
+	)
+	if flags.output == outputJSON {
+		// stdout is for the events only, the messages for humans go to stderr.
+		jsonOut = os.Stdout
+		events = gobuilder.NewJSONEventHandler(jsonOut)
+		out = os.Stderr
+	}
+	goArgs = builder.CLIBuildArgs(goArgs)
+	var confPath string
+	if flags.dryRun {
+		// a dry run does not create or migrate build.json.
+		confPath, err = builder.FindConf()
+	} else {
+		confPath, err = builder.InitConfTo(out, goArgs...)
+	}
+	if err != nil {
+		utils.PrintError(out, "%+v", err)
+		os.Exit(1)
+	}
+
+	profiles := []string{flags.profile}
+	if flags.profile == "" {
+		names, err := gobuilder.ProfileNames(confPath)
+		if err != nil {
+			utils.PrintError(out, "%+v", err)
+			os.Exit(1)
+		}
+		if len(names) > 1 && !flags.all {
+			utils.PrintError(out, "Choose a profile with --profile, or use --all. Profiles: %s", strings.Join(names, ", "))
+			os.Exit(1)
+		}
+		profiles = names
+	}
+
+	failed := false
+	for _, profile := range profiles {
+		opts := []gobuilder.Option{
+			gobuilder.WithConfigFile(confPath),
+			gobuilder.WithProfile(profile),
+			gobuilder.WithKeepGoing(flags.keepGoing),
+			gobuilder.WithForce(flags.force),
+			gobuilder.WithOutput(out),
+		}
+		if events != nil {
+			opts = append(opts, gobuilder.WithEvents(events))
+		}
+		if len(goArgs) > 0 && flags.replace {
+			opts = append(opts, gobuilder.WithBuildArgs(goArgs...))
+		} else if len(goArgs) > 0 {
+			opts = append(opts, gobuilder.WithMergedBuildArgs(goArgs...))
+		}
+		if len(flags.targets) > 0 {
+			opts = append(opts, gobuilder.WithTargetFilter(flags.targets...))
+		}
+		if flags.jobs > 0 {
+			opts = append(opts, gobuilder.WithParallel(flags.jobs))
+		}
+		gb, err := gobuilder.New(opts...)
+		if err != nil {
+			utils.PrintError(out, "%+v", err)
+			os.Exit(1)
+		}
+
+		if flags.save {
+			if err = gobuilder.SaveBuildArgs(confPath, gb.Name(), gb.BuildArgs()); err != nil {
+				utils.PrintError(out, "%+v", err)
+				os.Exit(1)
+			}
+			utils.PrintInfo(out, "Build args of %s are saved: %s", gb.Name(), strings.Join(gb.BuildArgs(), " "))
+		}
+
+		if flags.dryRun {
+			if err = printPlan(gb, out, jsonOut, flags.explain); err != nil {
+				utils.PrintError(out, "%+v", err)
+				os.Exit(1)
+			}
+			continue
+		}
+
+		result, err := gb.Build()
+		if result == nil {
+			utils.PrintError(out, "%+v", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(out, gprint.CyanStr("Build summary (%s):", gb.Name()))
+		result.PrintSummary(out)
+		if result.Manifest != "" {
+			utils.PrintInfo(out, "Manifest: %s", result.Manifest)
+		}
+		if err != nil {
+			utils.PrintError(out, "%+v", err)
+		}
+		if err != nil || result.Err() != nil {
+			failed = true
+			if !flags.keepGoing {
+				break
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+/*
+printPlan prints the plan of a dry run to out, or as JSON to jsonOut if it is not nil.
+*/
+func printPlan(gb *gobuilder.Builder, out, jsonOut io.Writer, explain bool) error {
+	plan, err := gb.Plan()
+	if err != nil {
+		return err
+	}
+	if jsonOut != nil {
+		return json.NewEncoder(jsonOut).Encode(plan)
+	}
+	plan.Print(out, explain)
+	return nil
+}
+
+/*
+runVerifyReproducible exits with code 1 if any artifact differs between two builds.
+*/
+func runVerifyReproducible(profile string) {
+	confPath, err := builder.InitConf()
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	gb, err := gobuilder.New(
+		gobuilder.WithConfigFile(confPath),
+		gobuilder.WithProfile(profile),
+	)
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	mismatches, err := gb.VerifyReproducible()
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	for _, m := range mismatches {
+		gprint.PrintWarning("%s %s: %s != %s", m.Target, m.File, m.Sums[0], m.Sums[1])
+	}
+	if len(mismatches) > 0 {
+		gprint.PrintError("%+v", gobuilder.ErrNotReproducible)
+		os.Exit(1)
+	}
+	gprint.PrintSuccess("All artifacts of %s are reproducible.", gb.Name())
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBuildFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    *buildFlags
+		goArgs  []string
+		wantErr bool
+	}{
+		{
+			name:   "no args",
+			want:   &buildFlags{output: outputText},
+			goArgs: []string{},
+		},
+		{
+			name:   "gber flags and go build args",
+			args:   []string{"--profile", "server", "--jobs=4", "--keep-going", "--", "-tags", "netgo", "./cmd/server"},
+			want:   &buildFlags{output: outputText, profile: "server", jobs: 4, keepGoing: true},
+			goArgs: []string{"-tags", "netgo", "./cmd/server"},
+		},
+		{
+			name:   "gber flags after -- are go build args",
+			args:   []string{"--", "--force", "-h"},
+			want:   &buildFlags{output: outputText},
+			goArgs: []string{"--force", "-h"},
+		},
+		{
+			name:   "targets",
+			args:   []string{"--target", "linux/arm64", "--targets", "windows/*, !windows/386,", "--target=darwin/arm64"},
+			want:   &buildFlags{output: outputText, targets: []string{"linux/arm64", "windows/*", "!windows/386", "darwin/arm64"}},
+			goArgs: []string{},
+		},
+		{
+			name:   "explain is a dry run",
+			args:   []string{"--explain", "--output", "json"},
+			want:   &buildFlags{output: outputJSON, dryRun: true, explain: true},
+			goArgs: []string{},
+		},
+		{
+			name:   "save and replace",
+			args:   []string{"--save", "--replace-args", "--", "./cmd/cli"},
+			want:   &buildFlags{output: outputText, save: true, replace: true},
+			goArgs: []string{"./cmd/cli"},
+		},
+		{
+			name:   "help",
+			args:   []string{"-h"},
+			want:   &buildFlags{output: outputText, help: true},
+			goArgs: []string{},
+		},
+		{name: "go build args without --", args: []string{"-ldflags", "-s -w"}, wantErr: true},
+		{name: "unknown flag", args: []string{"--nope"}, wantErr: true},
+		{name: "missing value", args: []string{"--profile"}, wantErr: true},
+		{name: "invalid jobs", args: []string{"--jobs", "0"}, wantErr: true},
+		{name: "invalid output", args: []string{"--output=yaml"}, wantErr: true},
+		{name: "all with profile", args: []string{"--all", "--profile", "cli"}, wantErr: true},
+		{name: "save without args", args: []string{"--save"}, wantErr: true},
+		{name: "replace without args", args: []string{"--replace-args", "--"}, wantErr: true},
+		{name: "save with dry run", args: []string{"--save", "--dry-run", "--", "./cmd/cli"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, goArgs, err := parseBuildFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBuildFlags(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(flags, tt.want) {
+				t.Errorf("parseBuildFlags(%q) flags = %+v, want %+v", tt.args, flags, tt.want)
+			}
+			if !reflect.DeepEqual(goArgs, tt.goArgs) {
+				t.Errorf("parseBuildFlags(%q) go args = %q, want %q", tt.args, goArgs, tt.goArgs)
+			}
+		})
+	}
+}
@@ -40,15 +40,165 @@ func (c *Cli) initiate() {
 		Use:                "build",
 		Aliases:            []string{"b"},
 		Short:              "Builds a go project.",
-		Long:               "Example: gber build --flags <args>.",
+		Long:               buildUsage,
 		GroupID:            GroupID,
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
-			bd := builder.NewBuilder()
-			bd.Build()
+			runBuild(args)
 		},
 	})
 
+	initCmd := &cobra.Command{
+		Use:     "init",
+		Aliases: []string{"i"},
+		Short:   "Creates the build config file.",
+		Long:    `Example: gber init --targets linux/amd64,windows/amd64 --zip --yes -- -ldflags "-s -w" ./cmd/app.`,
+		GroupID: GroupID,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			answer := func(name string) *bool {
+				if !flags.Changed(name) {
+					return nil
+				}
+				v, _ := flags.GetBool(name)
+				return &v
+			}
+			opts := &builder.InitOptions{
+				BuildArgs:        args,
+				EnableCGoWithXGo: answer("xgo"),
+				EnableZip:        answer("zip"),
+				EnableUPX:        answer("upx"),
+				EnableGarble:     answer("garble"),
+			}
+			opts.Targets, _ = flags.GetStringSlice("targets")
+			opts.ArchiveFormat, _ = flags.GetString("archive-format")
+			opts.CGoMode, _ = flags.GetString("cgo-mode")
+			opts.XGoImage, _ = flags.GetString("xgo-image")
+			opts.XGoDeps, _ = flags.GetString("xgo-deps")
+			opts.XGoDepsArgs, _ = flags.GetString("xgo-deps-args")
+			opts.OsslPfxFilePath, _ = flags.GetString("sign-pfx")
+			opts.OsslPfxPassword, _ = flags.GetString("sign-password")
+			opts.OsslPfxCompany, _ = flags.GetString("sign-company")
+			opts.OsslPfxWebsite, _ = flags.GetString("sign-website")
+			if flags.Changed("sign-pfx") {
+				sign := opts.OsslPfxFilePath != ""
+				opts.EnableSign = &sign
+			}
+			opts.MaxParallel, _ = flags.GetInt("jobs")
+			yes, _ := flags.GetBool("yes")
+			defaults, _ := flags.GetBool("defaults")
+			opts.Defaults = yes || defaults
+			opts.Force, _ = flags.GetBool("force")
+
+			confPath, err := builder.CreateConf(opts)
+			if err != nil {
+				gprint.PrintError("%+v", err)
+				os.Exit(1)
+			}
+			gprint.PrintSuccess("Build config file is saved to %s", confPath)
+		},
+	}
+	initCmd.Flags().StringSliceP("targets", "t", nil, "Target Os/Arch list, like linux/amd64,windows/amd64.")
+	initCmd.Flags().Bool("zip", false, "Zip binaries.")
+	initCmd.Flags().String("archive-format", "", "Archive format: zip(default), tar.gz, tar.xz or auto.")
+	initCmd.Flags().Bool("upx", false, "Pack binaries with UPX.")
+	initCmd.Flags().Bool("garble", false, "Obfuscate binaries with garble.")
+	initCmd.Flags().Bool("xgo", false, "Enable CGO with xgo.")
+	initCmd.Flags().String("cgo-mode", "", "Enable CGO with a C cross compiler: native, zig or xgo.")
+	initCmd.Flags().String("xgo-image", "", "Docker image for xgo.")
+	initCmd.Flags().String("xgo-deps", "", "CGO dependencies for xgo.")
+	initCmd.Flags().String("xgo-deps-args", "", "Build args of the CGO dependencies for xgo.")
+	initCmd.Flags().String("sign-pfx", "", "Pfx file for osslsigncode, signing is disabled if empty.")
+	initCmd.Flags().String("sign-password", "", "Pfx password reference for osslsigncode: env:VAR, file:/path or cmd:command. Default: "+builder.DefaultPfxPasswordRef)
+	initCmd.Flags().String("sign-company", "", "Company name for osslsigncode.")
+	initCmd.Flags().String("sign-website", "", "Website for osslsigncode.")
+	initCmd.Flags().Int("jobs", 0, "Number of targets built at the same time.")
+	initCmd.Flags().BoolP("yes", "y", false, "Use the defaults for options not given by flags, never ask.")
+	initCmd.Flags().Bool("defaults", false, "Same as --yes.")
+	initCmd.Flags().BoolP("force", "f", false, "Overwrite an existing build config file.")
+	c.rootCmd.AddCommand(initCmd)
+
+	validate := func(cmd *cobra.Command, args []string) {
+		confPath := builder.New().ConfPath()
+		if len(args) > 0 {
+			confPath = args[0]
+		}
+		issues, err := builder.ValidateConfFile(confPath)
+		if err != nil {
+			gprint.PrintError("%+v", err)
+			os.Exit(1)
+		}
+		failed := false
+		for _, issue := range issues {
+			if issue.Level == builder.IssueError {
+				failed = true
+				gprint.PrintError(issue.Message)
+			} else {
+				gprint.PrintWarning(issue.Message)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		gprint.PrintSuccess("%s is valid.", confPath)
+	}
+	configCmd := &cobra.Command{
+		Use:     "config",
+		Short:   "Manages the build config file, validates it by default.",
+		GroupID: GroupID,
+		Run:     validate,
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validates the build config file before building.",
+		Run:   validate,
+	})
+	c.rootCmd.AddCommand(configCmd)
+
+	verifyCmd := &cobra.Command{
+		Use:     "verify-reproducible",
+		Short:   "Builds twice in temp dirs, and checks that the artifacts are the same.",
+		Long:    "Example: gber verify-reproducible --profile server.",
+		GroupID: GroupID,
+		Run: func(cmd *cobra.Command, args []string) {
+			profile, _ := cmd.Flags().GetString("profile")
+			runVerifyReproducible(profile)
+		},
+	}
+	verifyCmd.Flags().String("profile", "", "Profile to verify.")
+	c.rootCmd.AddCommand(verifyCmd)
+
+	cacheCmd := &cobra.Command{
+		Use:     "cache",
+		Short:   "Shows the build cache.",
+		GroupID: GroupID,
+		Run: func(cmd *cobra.Command, args []string) {
+			dir, entries, size, err := builder.CacheInfo()
+			if err != nil {
+				gprint.PrintError("%+v", err)
+				os.Exit(1)
+			}
+			gprint.PrintInfo("%s: %d entries, %.1f MiB", dir, entries, float64(size)/(1<<20))
+		},
+	}
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Removes the cached artifacts.",
+		Long:  "Example: gber cache prune --older-than 168h.",
+		Run: func(cmd *cobra.Command, args []string) {
+			olderThan, _ := cmd.Flags().GetDuration("older-than")
+			removed, err := builder.PruneCache(olderThan)
+			if err != nil {
+				gprint.PrintError("%+v", err)
+				os.Exit(1)
+			}
+			gprint.PrintSuccess("%d entries are removed.", removed)
+		},
+	}
+	pruneCmd.Flags().Duration("older-than", 0, "Only remove the entries not used for the duration, like 168h.")
+	cacheCmd.AddCommand(pruneCmd)
+	c.rootCmd.AddCommand(cacheCmd)
+
 	c.rootCmd.AddCommand(&cobra.Command{
 		Use:     "version",
 		Aliases: []string{"v"},
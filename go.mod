@@ -4,15 +4,17 @@ go 1.18
 
 require (
 	github.com/charmbracelet/bubbles v0.16.1
+	github.com/charmbracelet/lipgloss v0.8.0
 	github.com/gvcgo/goutils v0.9.9
 	github.com/spf13/cobra v1.8.0
+	github.com/ulikunitz/xz v0.5.11
+	golang.org/x/term v0.15.0
 )
 
 require (
 	github.com/atotto/clipboard v0.1.4 // indirect
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
 	github.com/charmbracelet/bubbletea v0.24.2 // indirect
-	github.com/charmbracelet/lipgloss v0.8.0 // indirect
 	github.com/containerd/console v1.0.4-0.20230313162750-1ae8d489ac81 // indirect
 	github.com/erikgeiser/promptkit v0.9.0 // indirect
 	github.com/gogf/gf/v2 v2.6.1 // indirect
@@ -32,6 +34,5 @@ require (
 	go.opentelemetry.io/otel/trace v1.15.1 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
-	golang.org/x/term v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
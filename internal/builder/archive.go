@@ -0,0 +1,289 @@
+package builder
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+	"github.com/ulikunitz/xz"
+)
+
+/*
+Archive formats.
+
+ArchiveAuto uses zip for windows, and tar.gz for the others.
+*/
+const (
+	ArchiveZip   string = "zip"
+	ArchiveTarGz string = "tar.gz"
+	ArchiveTarXz string = "tar.xz"
+	ArchiveAuto  string = "auto"
+)
+
+func IsValidArchiveFormat(format string) bool {
+	switch format {
+	case "", ArchiveZip, ArchiveTarGz, ArchiveTarXz, ArchiveAuto:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Builder) archiveFormat(osInfo string) string {
+	switch b.ArchiveFormat {
+	case "":
+		return ArchiveZip
+	case ArchiveAuto:
+		if osInfo == gutils.Windows {
+			return ArchiveZip
+		}
+		return ArchiveTarGz
+	default:
+		return b.ArchiveFormat
+	}
+}
+
+/*
+ArchiveFile adds extra files to the archives, like README.md or LICENSE.
+
+Src is a glob pattern relative to the project dir, Dst is the dir inside the archive.
+In build.json, a plain string is the same as {"src": "<string>"}.
+*/
+type ArchiveFile struct {
+	Src string `json:"src"`
+	Dst string `json:"dst,omitempty"`
+}
+
+func (f *ArchiveFile) UnmarshalJSON(data []byte) error {
+	var src string
+	if err := json.Unmarshal(data, &src); err == nil {
+		f.Src = src
+		return nil
+	}
+	type archiveFile ArchiveFile
+	return json.Unmarshal(data, (*archiveFile)(f))
+}
+
+type archiveEntry struct {
+	src  string
+	name string // slash separated path inside the archive.
+	info os.FileInfo
+}
+
+/*
+archiveEntries lists the binary and the extra files to be archived.
+*/
+func (b *Builder) archiveEntries(binPath, binName, wrapDir string) (entries []*archiveEntry, err error) {
+	info, err := os.Stat(binPath)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &archiveEntry{src: binPath, name: path.Join(wrapDir, binName), info: info})
+
+	projectDir := b.ProjectDir()
+	for _, f := range b.ArchiveFiles {
+		matches, err := filepath.Glob(filepath.Join(projectDir, f.Src))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in archive_files: %w", f.Src, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %q in archive_files", f.Src)
+		}
+		for _, match := range matches {
+			root := filepath.Dir(match)
+			err = filepath.Walk(match, func(fPath string, fInfo os.FileInfo, err error) error {
+				if err != nil || fInfo.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(root, fPath)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, &archiveEntry{
+					src:  fPath,
+					name: path.Join(wrapDir, filepath.ToSlash(f.Dst), filepath.ToSlash(rel)),
+					info: fInfo,
+				})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+/*
+writeZip writes a zip file, mtime replaces the modification time of the files if it is not zero.
+*/
+func writeZip(dst string, entries []*archiveEntry, mtime time.Time) (err error) {
+	fw, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	defer fw.Close()
+	zw := zip.NewWriter(fw)
+
+	for idx, entry := range entries {
+		header, err := zip.FileInfoHeader(entry.info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		header.Method = zip.Deflate
+		if !mtime.IsZero() {
+			header.Modified = mtime
+			header.SetMode(0644)
+		}
+		if idx == 0 {
+			// the binary.
+			header.SetMode(0755)
+		}
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err = copyFile(writer, entry.src); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+/*
+writeTar writes a compressed tarball, the executable bit of the binary is kept.
+
+If mtime is not zero, it replaces the modification time of the files, and the owners are dropped.
+*/
+func writeTar(dst, format string, entries []*archiveEntry, mtime time.Time) (err error) {
+	fw, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	defer fw.Close()
+
+	var cw io.WriteCloser
+	if format == ArchiveTarXz {
+		if cw, err = xz.NewWriter(fw); err != nil {
+			return
+		}
+	} else {
+		cw = gzip.NewWriter(fw)
+	}
+	tw := tar.NewWriter(cw)
+
+	for idx, entry := range entries {
+		header, err := tar.FileInfoHeader(entry.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if !mtime.IsZero() {
+			header.ModTime = mtime
+			header.AccessTime = time.Time{}
+			header.ChangeTime = time.Time{}
+			header.Uid, header.Gid = 0, 0
+			header.Uname, header.Gname = "", ""
+			header.Mode = 0644
+		}
+		if idx == 0 {
+			// the binary.
+			header.Mode = 0755
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err = copyFile(tw, entry.src); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return
+	}
+	return cw.Close()
+}
+
+func copyFile(w io.Writer, src string) error {
+	fr, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	_, err = io.Copy(w, fr)
+	return err
+}
+
+/*
+archivePath returns the path of the archive from the archive name template, and the name without the extension.
+*/
+func (b *Builder) archivePath(osInfo, archInfo, binDir, format string) (archivePath, baseName string, err error) {
+	_, name := b.mainArgs()
+	baseName, err = renderTemplate(b.NameTemplates.Archive, DefaultArchiveTemplate, b.nameData(osInfo, archInfo, name))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid archive name template: %w", err)
+	}
+	return filepath.Join(filepath.Dir(binDir), fmt.Sprintf("%s.%s", baseName, format)), baseName, nil
+}
+
+/*
+Archive packs the binary and the archive_files into build/, the name of the archive is from the archive name template.
+*/
+func (b *Builder) Archive(osInfo, archInfo, binDir, binName string) (archivePath string, err error) {
+	if !b.EnableZip {
+		return "", nil
+	}
+	format := b.archiveFormat(osInfo)
+	target := osInfo + "/" + archInfo
+	if b.variant != "" {
+		target += "/" + b.variant
+	}
+	fmt.Fprintln(b.out(), gprint.YellowStr("Archiving binaries to %s for %s...", format, target))
+
+	binPath := filepath.Join(binDir, binName)
+	archivePath, baseName, err := b.archivePath(osInfo, archInfo, binDir, format)
+	if err != nil {
+		return "", err
+	}
+
+	wrapDir := ""
+	if b.ArchiveWrapDir {
+		wrapDir = baseName
+	}
+	entries, err := b.archiveEntries(binPath, binName, wrapDir)
+	if err != nil {
+		return "", err
+	}
+
+	var mtime time.Time
+	if b.Reproducible {
+		mtime = b.sourceDate
+		// the binary stays first.
+		extras := entries[1:]
+		sort.Slice(extras, func(i, j int) bool { return extras[i].name < extras[j].name })
+	}
+
+	switch format {
+	case ArchiveZip:
+		err = writeZip(archivePath, entries, mtime)
+	case ArchiveTarGz, ArchiveTarXz:
+		err = writeTar(archivePath, format, entries, mtime)
+	default:
+		err = fmt.Errorf("%w: %s", ErrArchiveFormat, format)
+	}
+	if err != nil {
+		os.RemoveAll(archivePath)
+		return "", err
+	}
+	return archivePath, nil
+}
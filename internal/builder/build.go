@@ -2,12 +2,14 @@ package builder
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/gvcgo/gobuilder/internal/utils"
-	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
 
@@ -21,62 +23,223 @@ const (
 )
 
 type Builder struct {
-	WorkDir            string   `json:"work_dir"`
-	ArchOSList         []string `json:"arch_os_list"`
-	BuildArgs          []string `json:"build_args"`
-	EnableCGoWithXGo   bool     `json:"enable_cgo_with_xgo"`
-	XGoImage           string   `json:"xgo_image"`
-	XGoDeps            string   `json:"xgo_deps"`
-	XGoDepsArgs        string   `json:"xgo_deps_args"`
-	EnableZip          bool     `json:"enable_zip"`
-	EnableGarble       bool     `json:"enable_garble"`
-	EnableUPX          bool     `json:"enable_upx"`
-	EnableOsslsigncode bool     `json:"enable_osslsigncode"`
-	OsslPfxFilePath    string   `json:"ossl_pfx_file_path"`
-	OsslPfxPassword    string   `json:"ossl_pfx_password"`
-	OsslPfxCompany     string   `json:"ossl_pfx_company"`
-	OsslPfxWebsite     string   `json:"ossl_pfx_website"`
-}
-
-func NewBuilder() (b *Builder) {
-	b = &Builder{
-		ArchOSList: []string{},
-		BuildArgs:  []string{},
-	}
-	b.LoadConf()
-	return b
+	Version            int                        `json:"version"`
+	Name               string                     `json:"name,omitempty"`
+	WorkDir            string                     `json:"work_dir"`
+	ArchOSList         []string                   `json:"arch_os_list"`
+	BuildArgs          []string                   `json:"build_args"`
+	EnableCGoWithXGo   bool                       `json:"enable_cgo_with_xgo,omitempty"` // Deprecated: use cgo_mode "xgo".
+	CGoMode            string                     `json:"cgo_mode"`                      // native, zig or xgo, CGO is disabled if empty.
+	XGoImage           string                     `json:"xgo_image"`
+	XGoDeps            string                     `json:"xgo_deps"`
+	XGoDepsArgs        string                     `json:"xgo_deps_args"`
+	EnableZip          bool                       `json:"enable_zip"`
+	ArchiveFormat      string                     `json:"archive_format"` // zip(default), tar.gz, tar.xz or auto.
+	ArchiveFiles       []*ArchiveFile             `json:"archive_files"`
+	ArchiveWrapDir     bool                       `json:"archive_wrap_dir"` // wraps everything in a top level dir named like the archive.
+	NameTemplates      NameTemplates              `json:"name_templates"`
+	OsAliases          map[string]string          `json:"os_aliases"`
+	ArchAliases        map[string]string          `json:"arch_aliases"` // like {"amd64": "x86_64"}.
+	EnableGarble       bool                       `json:"enable_garble"`
+	EnableUPX          bool                       `json:"enable_upx"`
+	EnableOsslsigncode bool                       `json:"enable_osslsigncode"`
+	OsslPfxFilePath    string                     `json:"ossl_pfx_file_path"`
+	OsslPfxPassword    string                     `json:"ossl_pfx_password"`
+	OsslPfxCompany     string                     `json:"ossl_pfx_company"`
+	OsslPfxWebsite     string                     `json:"ossl_pfx_website"`
+	MaxParallel        int                        `json:"max_parallel"`
+	EnableSHA512       bool                       `json:"enable_sha512"`
+	Reproducible       bool                       `json:"reproducible"` // same source, same artifacts.
+	Env                map[string]string          `json:"env"`          // overrides the defaults like CGO_ENABLED=0.
+	Overrides          map[string]*TargetOverride `json:"overrides"`    // keyed by Os/Arch or glob.
+	Hooks              Hooks                      `json:"hooks"`
+	Pipeline           []*PipelineStep            `json:"pipeline"` // steps after compiling, DefaultPipeline if empty.
+	Profiles           []json.RawMessage          `json:"profiles,omitempty"`
+	KeepGoing          bool                       `json:"-"` // keeps building the other targets after one fails.
+	Force              bool                       `json:"-"` // builds without the cache.
+	Events             EventHandler               `json:"-"` // receives the events of the build, like target_started.
+	Out                io.Writer                  `json:"-"` // of the messages and the compiler output, os.Stdout if nil.
+	cliArgs            []string
+	argsNotes          []string // the build args merged or replaced for this run, for the plan.
+	vars               *BuildVars
+	sourceDate         time.Time // commit time for the reproducible mode.
+	sourceHash         string    // of the project files, the cache is disabled if empty.
+	variant            string    // sub-architecture of the target, like v7 for linux/arm/v7.
+	override           *TargetOverride
+	missingTools       *[]string // notes of the missing tools in a dry run, nil in a real build.
 }
 
+func New() *Builder {
+	return &Builder{
+		Version:      ConfVersion,
+		ArchOSList:   []string{},
+		BuildArgs:    []string{},
+		ArchiveFiles: []*ArchiveFile{},
+	}
+}
+
+/*
+InitConf makes sure that the build config file exists.
+
+The user is asked for the build options if the file does not exist yet.
+*/
+func InitConf(cliArgs ...string) (confPath string, err error) {
+	return InitConfTo(os.Stdout, cliArgs...)
+}
+
+/*
+InitConfTo is InitConf with the messages, like the migrated fields, written to out.
+*/
+func InitConfTo(out io.Writer, cliArgs ...string) (confPath string, err error) {
+	b := New()
+	b.cliArgs = cliArgs
+	b.Out = out
+	if err = b.LoadConf(); err != nil {
+		return
+	}
+	return b.ConfPath(), nil
+}
+
+/*
+ProjectDir finds the go project of WorkDir, or of the current working dir if WorkDir is not set.
+*/
 func (b *Builder) ProjectDir() string {
-	var projectDir string
-	cwd, _ := os.Getwd()
-	if cwd != "" {
-		projectDir = utils.FindGoProjectDir(cwd)
+	dir := b.WorkDir
+	if dir == "" {
+		dir, _ = os.Getwd()
 	}
-	return projectDir
+	if dir == "" {
+		return ""
+	}
+	return utils.FindGoProjectDir(dir)
 }
 
-func (b *Builder) LoadConf() {
+func (b *Builder) ConfPath() string {
 	projectDir := b.ProjectDir()
 	if projectDir == "" {
-		gprint.PrintError("not found go project")
-		os.Exit(1)
-	}
-	buildConf := filepath.Join(projectDir, "build", ConfFileName)
-	if ok, _ := gutils.PathIsExist(buildConf); ok {
-		data, _ := os.ReadFile(buildConf)
-		if err := json.Unmarshal(data, b); err != nil {
-			gprint.PrintError("Failed to load build config file: %+v", err)
-			os.Exit(1)
+		return ""
+	}
+	return filepath.Join(projectDir, "build", ConfFileName)
+}
+
+/*
+FindConf returns the path of the build config file of the project, without creating or migrating it.
+*/
+func FindConf() (confPath string, err error) {
+	if confPath = New().ConfPath(); confPath == "" {
+		return "", newStepError("", StepConfig, ErrProjectNotFound)
+	}
+	if ok, _ := gutils.PathIsExist(confPath); !ok {
+		return "", newStepError("", StepConfig, ErrConfNotFound)
+	}
+	return confPath, nil
+}
+
+/*
+LoadConf loads the build config file of the project, and creates it interactively if it does not exist.
+*/
+func (b *Builder) LoadConf() error {
+	buildConf := b.ConfPath()
+	if buildConf == "" {
+		return newStepError("", StepConfig, ErrProjectNotFound)
+	}
+	if ok, _ := gutils.PathIsExist(buildConf); !ok {
+		return newStepError("", StepConfig, b.saveBuilder(buildConf, &InitOptions{}))
+	}
+
+	changes, err := MigrateConfFile(buildConf)
+	if err != nil {
+		return newStepError("", StepConfig, fmt.Errorf("failed to migrate build config file: %w", err))
+	}
+	if len(changes) > 0 {
+		utils.PrintInfo(b.out(), "Build config file is migrated to version %d:", ConfVersion)
+		for _, line := range changes {
+			fmt.Fprintln(b.out(), line)
 		}
-	} else {
-		b.saveBuilder(buildConf)
 	}
+	return b.LoadConfFile(buildConf)
+}
+
+/*
+LoadConfFile loads a build config file without any interaction.
+*/
+func (b *Builder) LoadConfFile(buildConf string) error {
+	data, err := os.ReadFile(buildConf)
+	if err != nil {
+		return newStepError("", StepConfig, err)
+	}
+	if data, _, err = migrateConf(data); err != nil {
+		return newStepError("", StepConfig, fmt.Errorf("failed to migrate build config file: %w", err))
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return newStepError("", StepConfig, fmt.Errorf("failed to load build config file: %w", err))
+	}
+	return nil
 }
 
-func (b *Builder) build(osInfo, archInfo string) {
-	gprint.PrintInfo("Building for %s/%s...", osInfo, archInfo)
-	inputArgs, binDir, binName := b.PrepareArgs(osInfo, archInfo)
+/*
+targetEnv returns the env of the compiler for the target.
+The env in build.json overrides the defaults, and the env of the target overrides the global one.
+*/
+func (b *Builder) targetEnv(osInfo, archInfo string) ([]string, error) {
+	env, err := b.buildEnv(osInfo, archInfo)
+	if err != nil {
+		return nil, err
+	}
+	return append(os.Environ(), env...), nil
+}
+
+/*
+buildEnv returns the env vars set by gber for the target, without the env of the process.
+*/
+func (b *Builder) buildEnv(osInfo, archInfo string) ([]string, error) {
+	env := append(
+		[]string{},
+		fmt.Sprintf("GOOS=%s", osInfo),
+		fmt.Sprintf("GOARCH=%s", archInfo),
+		"CGO_ENABLED=0", // disable CGO by default.
+	)
+	if e := variantEnv(archInfo, b.variant); e != "" {
+		env = append(env, e)
+	}
+	if b.Reproducible {
+		env = append(env, fmt.Sprintf("SOURCE_DATE_EPOCH=%d", b.sourceDate.Unix()))
+	}
+	cgoEnv, err := b.cgoEnv(osInfo, archInfo)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, cgoEnv...)
+
+	_, name := b.mainArgs()
+	data := b.nameData(osInfo, archInfo, name)
+	custom := []map[string]string{b.Env}
+	if b.override != nil {
+		custom = append(custom, b.override.Env)
+	}
+	for _, m := range custom {
+		expanded, err := b.expandEnv(m, data)
+		if err != nil {
+			return nil, err
+		}
+		// the last value of a key wins.
+		env = append(env, expanded...)
+	}
+	return env, nil
+}
+
+/*
+targetCommand returns the command of the compiler for the target, like go build, garble or xgo.
+notes say why the build args are added or rewritten.
+*/
+func (b *Builder) targetCommand(result *TargetResult) (args []string, binDir, binName string, notes []string, err error) {
+	osInfo, archInfo := result.Os, result.Arch
+	inputArgs, binDir, binName, notes, err := b.prepareArgs(osInfo, archInfo)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	inputArgs = b.applyOverride(inputArgs)
+	notes = append(notes, b.explainOverride()...)
 
 	compiler := []string{
 		"go",
@@ -86,50 +249,291 @@ func (b *Builder) build(osInfo, archInfo string) {
 	if b.EnableGarble {
 		// Enable garble
 		compiler = []string{"garble", "-literals", "-tiny", "-seed=random", "build"}
+		notes = append(notes, "garble replaces go build, enable_garble is true")
+		if b.Reproducible {
+			// garble is deterministic without a random seed.
+			compiler = []string{"garble", "-literals", "-tiny", "build"}
+			notes = append(notes, "-seed=random of garble is dropped in the reproducible mode")
+		}
+	}
+	if b.Reproducible {
+		inputArgs = reproducibleArgs(inputArgs)
+		notes = append(notes, "-trimpath and -buildvcs=false are enforced in the reproducible mode")
 	}
 
-	args := append(compiler, inputArgs...)
-	b.handleInjections(args)
-
-	os.Setenv("GOOS", osInfo)
-	os.Setenv("GOARCH", archInfo)
-	os.Setenv("CGO_ENABLED", "0") // disable CGO by default.
+	args = append(compiler, inputArgs...)
+	raw := append([]string{}, args...)
+	if err = b.renderArgs(args, osInfo, archInfo); err != nil {
+		return nil, "", "", nil, err
+	}
+	for idx, arg := range args {
+		if arg != raw[idx] {
+			notes = append(notes, fmt.Sprintf("%q is rendered to %q", raw[idx], arg))
+		}
+	}
+	rendered := append([]string{}, args...)
+	if err = b.handleInjections(args); err != nil {
+		return nil, "", "", nil, err
+	}
+	for idx, arg := range args {
+		if arg != rendered[idx] {
+			notes = append(notes, fmt.Sprintf("the command substitutions in %q give %q", rendered[idx], arg))
+		}
+	}
 
 	// CGO with xgo
-	if b.EnableCGoWithXGo {
-		args = b.UseXGO(osInfo, archInfo, binDir, binName, args)
+	if b.cgoMode() == CGoModeXgo {
+		if args, err = b.UseXGO(osInfo, archInfo, binDir, binName, args); err != nil {
+			return nil, "", "", nil, err
+		}
+		notes = append(notes, "the command is rewritten for xgo, cgo_mode is xgo: -race is added, only -ldflags, -trimpath, -v and -x are kept")
 	}
+	return args, binDir, binName, notes, nil
+}
 
-	if _, err := gutils.ExecuteSysCommand(false, b.WorkDir, args...); err != nil {
-		gprint.PrintError("Failed to build binaries: %+v", err)
-		os.Exit(1)
+func (b *Builder) build(result *TargetResult, out io.Writer) (err error) {
+	osInfo, archInfo := result.Os, result.Arch
+	utils.PrintInfo(b.out(), "Building for %s...", result.Target)
+	b.emit(&Event{Type: EventTargetStarted, Target: result.Target})
+
+	_, name := b.mainArgs()
+	hookData := b.nameData(osInfo, archInfo, name)
+	if err = b.runHooks("before_each", result.Target, b.Hooks.BeforeEach, hookData, b.hookEnv(result, nil), out); err != nil {
+		return newStepError(result.Target, StepHook, err)
 	}
 
-	if b.EnableCGoWithXGo {
-		b.FixBinaryName(osInfo, archInfo, binDir, binName)
+	args, binDir, binName, _, err := b.targetCommand(result)
+	if err != nil {
+		return newStepError(result.Target, StepPrepare, err)
 	}
+	result.Args = args
 
-	// UPX
-	b.PackWithUPX(osInfo, archInfo, binDir, binName)
+	env, err := b.targetEnv(osInfo, archInfo)
+	if err != nil {
+		return newStepError(result.Target, StepPrepare, err)
+	}
+	key := b.targetCacheKey(result.Target, args, env)
+	if binary, archive, ok := b.restoreCache(key); ok {
+		utils.PrintInfo(b.out(), "Using cached artifacts for %s.", result.Target)
+		result.Binary, result.Archive, result.Cached = binary, archive, true
+	} else {
+		if err = b.compile(result, args, env, binDir, binName, out); err != nil {
+			return err
+		}
+		if err = b.saveCache(key, result.Binary, result.Archive); err != nil {
+			utils.PrintWarning(b.out(), "failed to cache artifacts for %s: %v", result.Target, err)
+		}
+	}
+	b.emit(&Event{Type: EventArtifact, Target: result.Target, Kind: ArtifactBinary, Path: result.Binary})
+	if result.Archive != "" {
+		b.emit(&Event{Type: EventArtifact, Target: result.Target, Kind: ArtifactArchive, Path: result.Archive})
+	}
 
-	// Osslsigncode
-	b.SignWithOsslsigncode(osInfo, archInfo, binDir, binName)
+	if err = b.runHooks("after_each", result.Target, b.Hooks.AfterEach, hookData, b.hookEnv(result, nil), out); err != nil {
+		return newStepError(result.Target, StepHook, err)
+	}
+	return nil
+}
 
-	// Zip
-	b.Zip(osInfo, archInfo, binDir, binName)
+/*
+compile runs the compiler and the pipeline for the target.
+*/
+func (b *Builder) compile(result *TargetResult, args, env []string, binDir, binName string, out io.Writer) error {
+	if err := os.MkdirAll(binDir, os.ModePerm); err != nil {
+		return newStepError(result.Target, StepCompile, err)
+	}
+	b.emit(&Event{Type: EventCommand, Target: result.Target, Step: StepCompile, Command: args})
+	start := time.Now()
+	if err := utils.ExecuteCommand(b.WorkDir, env, out, out, args...); err != nil {
+		return newStepError(result.Target, StepCompile, err)
+	}
+	b.emit(&Event{Type: EventStepFinished, Target: result.Target, Step: StepCompile, DurationMs: time.Since(start).Milliseconds()})
+
+	if b.cgoMode() == CGoModeXgo {
+		b.FixBinaryName(result.Os, result.Arch, binDir, binName)
+	}
+
+	// UPX, osslsigncode, archiving and the custom steps.
+	artifact, err := b.runPipeline(&StepArtifact{
+		Target:  result.Target,
+		Os:      result.Os,
+		Arch:    result.Arch,
+		Variant: result.Variant,
+		BinDir:  binDir,
+		BinName: binName,
+	}, env, out)
+	if err != nil {
+		return err
+	}
+	result.Binary = artifact.Binary()
+	result.Archive = artifact.Archive
+	return nil
+}
+
+/*
+resolveVars resolves the build variables, and the source date in the reproducible mode.
+*/
+func (b *Builder) resolveVars() (err error) {
+	b.vars = ResolveBuildVars(b.WorkDir)
+	if b.Reproducible {
+		if b.sourceDate, err = SourceDateEpoch(b.WorkDir); err != nil {
+			return newStepError("", StepPrepare, err)
+		}
+		b.vars.setBuildTime(b.sourceDate)
+		for _, arg := range b.BuildArgs {
+			if hasSubstitution(arg) {
+				utils.PrintWarning(b.out(), "command substitutions may not be reproducible: %s", arg)
+			}
+		}
+	}
+	return nil
 }
 
-func (b *Builder) Build() {
+/*
+Build builds all the targets in ArchOSList.
+
+Failures of single targets are collected in the report.
+Unless KeepGoing is set, no more targets are started after a failure.
+The report is also returned when writing the checksums or the manifest fails.
+*/
+func (b *Builder) Build() (report *Report, err error) {
 	if !IsGoCompilerInstalled() {
-		gprint.PrintError("go compiler is not installed.")
-		return
+		return nil, ErrGoNotInstalled
 	}
 
+	defer func() {
+		if err != nil {
+			b.emitError("", err)
+		}
+		if report != nil {
+			b.emitSummary(report, err)
+		}
+	}()
+
+	report = &Report{Results: make([]*TargetResult, len(b.ArchOSList))}
 	if len(b.ArchOSList) == 0 {
-		return
+		return report, nil
+	}
+	if err = b.resolveVars(); err != nil {
+		return nil, err
 	}
-	for _, osArch := range b.ArchOSList {
-		sList := strings.Split(osArch, "/")
-		b.build(sList[0], sList[1])
+	report.Variables = b.vars
+	utils.PrintInfo(b.out(), "Build variables: %s", b.vars)
+
+	_, name := b.mainArgs()
+	if err = b.runHooks("before", "", b.Hooks.Before, b.nameData("", "", name), b.hookEnv(nil, nil), b.out()); err != nil {
+		return nil, newStepError("", StepHook, err)
+	}
+
+	// after the before hooks, they may generate code.
+	if !b.Force {
+		var hashErr error
+		if b.sourceHash, hashErr = hashSources(b.ProjectDir()); hashErr != nil {
+			utils.PrintWarning(b.out(), "build cache is disabled: %v", hashErr)
+		}
+	}
+
+	jobs := b.MaxParallel
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(b.ArchOSList) {
+		jobs = len(b.ArchOSList)
+	}
+
+	var (
+		failed     bool
+		aborted    bool // by a failing hook.
+		failedLock = &sync.Mutex{}
+	)
+	indexes := make(chan int)
+	outputLock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				osArch := b.ArchOSList[idx]
+				result := &TargetResult{Target: osArch}
+				report.Results[idx] = result
+				var err error
+				if result.Os, result.Arch, result.Variant, err = ParseTarget(osArch); err != nil {
+					result.Err = newStepError(osArch, StepPrepare, err)
+					utils.PrintError(b.out(), "%+v", result.Err)
+					b.emitError(osArch, result.Err)
+					b.emit(&Event{Type: EventTargetFinished, Target: osArch, Status: result.Status()})
+					failedLock.Lock()
+					failed = true
+					failedLock.Unlock()
+					continue
+				}
+
+				failedLock.Lock()
+				skip := (failed && !b.KeepGoing) || aborted
+				failedLock.Unlock()
+				if skip {
+					result.Err = ErrSkipped
+					b.emit(&Event{Type: EventTargetFinished, Target: osArch, Status: result.Status()})
+					continue
+				}
+
+				var (
+					out io.Writer = b.out()
+					pw  *utils.PrefixWriter
+				)
+				if jobs > 1 {
+					// Prefix the compiler output of each target, so that parallel builds stay readable.
+					pw = utils.NewPrefixWriter(fmt.Sprintf("[%s] ", osArch), b.out(), outputLock)
+					out = pw
+				}
+
+				start := time.Now()
+				result.Err = b.forTarget(result).build(result, out)
+				result.Duration = time.Since(start)
+				if pw != nil {
+					pw.Flush()
+				}
+
+				if result.Err != nil {
+					utils.PrintError(b.out(), "%+v", result.Err)
+					b.emitError(osArch, result.Err)
+					failedLock.Lock()
+					failed = true
+					aborted = aborted || isHookError(result.Err)
+					failedLock.Unlock()
+				}
+				b.emit(&Event{
+					Type:       EventTargetFinished,
+					Target:     osArch,
+					Status:     result.Status(),
+					DurationMs: result.Duration.Milliseconds(),
+				})
+			}
+		}()
+	}
+	for idx := range b.ArchOSList {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Checksums and manifest of the artifacts.
+	if err = b.writeReleaseFiles(report); err != nil {
+		return report, newStepError("", StepRelease, err)
+	}
+	for _, checksums := range report.Checksums {
+		b.emit(&Event{Type: EventArtifact, Kind: ArtifactChecksums, Path: checksums})
+	}
+	if report.Manifest != "" {
+		b.emit(&Event{Type: EventArtifact, Kind: ArtifactManifest, Path: report.Manifest})
+	}
+
+	// after hooks only run if all targets are built.
+	if report.Err() == nil {
+		if err = b.runHooks("after", "", b.Hooks.After, b.nameData("", "", name), b.hookEnv(nil, report), b.out()); err != nil {
+			return report, newStepError("", StepHook, err)
+		}
 	}
+	return report, nil
 }
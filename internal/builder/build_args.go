@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"github.com/gvcgo/gobuilder/internal/utils"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
 
@@ -15,30 +15,238 @@ const (
 )
 
 /*
-prepare build args
+renderArgs resolves the built-in variables like {{.GitTag}} in the args, see BuildVars and NameData.
 */
-func (b *Builder) handleInjections(args []string) {
-	// handle injection args
-	cmdReg := regexp.MustCompile(`(\$\([\w\W]+?\))`)
+func (b *Builder) renderArgs(args []string, osInfo, archInfo string) error {
+	_, name := b.mainArgs()
+	data := b.nameData(osInfo, archInfo, name)
 	for idx, v := range args {
-		l := cmdReg.FindAllString(v, -1)
-		for _, cc := range l {
-			ccc := strings.TrimLeft(cc, "$(")
-			ccc = strings.TrimRight(ccc, ")")
-			bf, _ := gutils.ExecuteSysCommand(true, b.WorkDir, strings.Split(ccc, " ")...)
-			v = strings.ReplaceAll(v, cc, bf.String())
+		if !strings.Contains(v, "{{") {
+			continue
 		}
-		if len(l) > 0 {
-			args[idx] = v
+		rendered, err := renderTemplate(v, "", data)
+		if err != nil {
+			return fmt.Errorf("invalid template %q in build args: %w", v, err)
 		}
+		args[idx] = rendered
 	}
+	return nil
 }
 
-func (b *Builder) PrepareArgs(osInfo, archInfo string) (args []string, targetDir, binName string) {
-	inputArgs := append([]string{}, b.BuildArgs...) // deepcopy
+/*
+handleInjections replaces command substitutions like $(git describe --tags) in the args with the output of the commands.
+
+The commands are split like in a shell, so quoted arguments and nested substitutions work.
+*/
+func (b *Builder) handleInjections(args []string) error {
+	for idx, v := range args {
+		expanded, err := b.expandSubstitutions(v)
+		if err != nil {
+			return err
+		}
+		args[idx] = expanded
+	}
+	return nil
+}
+
+/*
+hashSubstitutions converts "#(...)" to "$(...)", other "#" like in "-X main.Color=#fff" are kept.
+*/
+func hashSubstitutions(s string) string {
+	return strings.ReplaceAll(s, "#(", "$(")
+}
+
+// hasSubstitution reports whether s has a command substitution, "$(...)" or "#(...)".
+func hasSubstitution(s string) bool {
+	return strings.Contains(s, "$(") || strings.Contains(s, "#(")
+}
+
+func (b *Builder) expandSubstitutions(s string) (string, error) {
+	result := strings.Builder{}
+	for {
+		start, end, err := utils.FindSubstitution(s)
+		if err != nil {
+			return "", err
+		}
+		if start < 0 {
+			result.WriteString(s)
+			return result.String(), nil
+		}
+
+		expr := s[start:end]
+		// nested substitutions first.
+		command, err := b.expandSubstitutions(expr[2 : len(expr)-1])
+		if err != nil {
+			return "", err
+		}
+		output, err := b.runSubstitution(command)
+		if err != nil {
+			return "", fmt.Errorf("command substitution %s failed: %w", expr, err)
+		}
+		result.WriteString(s[:start])
+		result.WriteString(output)
+		s = s[end:]
+	}
+}
+
+func (b *Builder) runSubstitution(command string) (string, error) {
+	words, err := utils.SplitShellWords(command)
+	if err != nil {
+		return "", err
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	buf, err := gutils.ExecuteSysCommand(true, b.WorkDir, words...)
+	if err != nil {
+		return "", err
+	}
+	// like a shell, trailing newlines are removed.
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+/*
+goBuildValueFlags are the flags of go build that take the next arg as the value, like "-tags netgo".
+*/
+var goBuildValueFlags = map[string]bool{
+	"C":             true,
+	"o":             true,
+	"p":             true,
+	"asmflags":      true,
+	"buildmode":     true,
+	"compiler":      true,
+	"covermode":     true,
+	"coverpkg":      true,
+	"gccgoflags":    true,
+	"gcflags":       true,
+	"installsuffix": true,
+	"ldflags":       true,
+	"mod":           true,
+	"modfile":       true,
+	"overlay":       true,
+	"pgo":           true,
+	"pkgdir":        true,
+	"tags":          true,
+	"toolexec":      true,
+}
+
+// buildFlagName returns the name of a flag like "-tags", "--tags=a" or "-v", empty if arg is not a flag.
+func buildFlagName(arg string) string {
+	if !strings.HasPrefix(arg, "-") {
+		return ""
+	}
+	return strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+}
+
+/*
+splitBuildArgs splits the args of go build to the flags with their values, and the packages.
+*/
+func splitBuildArgs(args []string) (flags [][]string, pkgs []string) {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		name := buildFlagName(arg)
+		switch {
+		case name == "":
+			pkgs = append(pkgs, arg)
+		case goBuildValueFlags[name] && !strings.Contains(arg, "=") && idx+1 < len(args):
+			flags = append(flags, args[idx:idx+2])
+			idx++
+		default:
+			flags = append(flags, args[idx:idx+1])
+		}
+	}
+	return
+}
+
+/*
+MergeBuildArgs merges args into the saved build args.
+
+A flag in args replaces the same flag in saved, like "-ldflags" or "-tags", the other flags are added.
+The packages in args replace the saved ones.
+*/
+func MergeBuildArgs(saved, args []string) []string {
+	savedFlags, savedPkgs := splitBuildArgs(saved)
+	newFlags, newPkgs := splitBuildArgs(args)
+
+	replaced := map[string]bool{}
+	for _, f := range newFlags {
+		replaced[buildFlagName(f[0])] = true
+	}
+	merged := []string{}
+	for _, f := range savedFlags {
+		if !replaced[buildFlagName(f[0])] {
+			merged = append(merged, f...)
+		}
+	}
+	for _, f := range newFlags {
+		merged = append(merged, f...)
+	}
+	if len(newPkgs) > 0 {
+		savedPkgs = newPkgs
+	}
+	return append(merged, savedPkgs...)
+}
+
+/*
+MergeArgs merges args into the build args with MergeBuildArgs,
+the plan notes the flags and packages of build_args that are replaced or added.
+*/
+func (b *Builder) MergeArgs(args []string) {
+	b.argsNotes = explainMergeBuildArgs(b.BuildArgs, args)
+	b.BuildArgs = MergeBuildArgs(b.BuildArgs, args)
+}
+
+/*
+ReplaceArgs replaces the build args, the plan notes the replaced build_args.
+*/
+func (b *Builder) ReplaceArgs(args []string) {
+	b.argsNotes = nil
+	if len(b.BuildArgs) > 0 {
+		b.argsNotes = []string{fmt.Sprintf("build_args %q are replaced by the args of this run", b.BuildArgs)}
+	}
+	b.BuildArgs = append([]string{}, args...)
+}
+
+func explainMergeBuildArgs(saved, args []string) (notes []string) {
+	savedFlags, savedPkgs := splitBuildArgs(saved)
+	newFlags, newPkgs := splitBuildArgs(args)
+	savedNames := map[string]bool{}
+	for _, f := range savedFlags {
+		savedNames[buildFlagName(f[0])] = true
+	}
+	for _, f := range newFlags {
+		if name := buildFlagName(f[0]); savedNames[name] {
+			notes = append(notes, fmt.Sprintf("-%s of build_args is replaced by %q of this run", name, f))
+		} else {
+			notes = append(notes, fmt.Sprintf("%q is added to build_args by this run", f))
+		}
+	}
+	if len(newPkgs) > 0 && len(savedPkgs) > 0 {
+		notes = append(notes, fmt.Sprintf("the packages %q of build_args are replaced by %q of this run", savedPkgs, newPkgs))
+	} else if len(newPkgs) > 0 {
+		notes = append(notes, fmt.Sprintf("the packages %q are added to build_args by this run", newPkgs))
+	}
+	return notes
+}
+
+/*
+mainArgs rewrites the main package position, and takes -o out of the args.
+name is the name of the binary without the name templates.
+*/
+func (b *Builder) mainArgs() (inputArgs []string, name string) {
+	inputArgs, name, _ = b.explainMainArgs()
+	return
+}
+
+/*
+explainMainArgs is mainArgs, notes say why the args are rewritten.
+*/
+func (b *Builder) explainMainArgs() (inputArgs []string, name string, notes []string) {
+	inputArgs = append([]string{}, b.BuildArgs...) // deepcopy
 
 	if len(inputArgs) == 0 {
 		inputArgs = append(inputArgs, b.WorkDir)
+		notes = append(notes, fmt.Sprintf("no build args, the work dir %s is the main package", b.WorkDir))
 	}
 
 	// main func position.
@@ -47,28 +255,59 @@ func (b *Builder) PrepareArgs(osInfo, archInfo string) (args []string, targetDir
 	if !strings.HasPrefix(lastArg, string([]rune{filepath.Separator})) && !strings.HasPrefix(lastArg, ".") {
 		inputArgs = append(inputArgs, b.WorkDir)
 		lastArg = b.WorkDir
+		notes = append(notes, fmt.Sprintf("the last arg %q is not a path, the work dir %s is appended as the main package", inputArgs[len(inputArgs)-2], b.WorkDir))
 	} else if lastArg == "." && b.WorkDir != "" {
 		inputArgs[len(inputArgs)-1] = b.WorkDir
 		lastArg = b.WorkDir
+		notes = append(notes, fmt.Sprintf("the main package \".\" is replaced with the work dir %s", b.WorkDir))
 	} else if lastArg == ".." && b.WorkDir != "" {
 		inputArgs[len(inputArgs)-1] = filepath.Dir(b.WorkDir)
 		lastArg = b.WorkDir
+		notes = append(notes, fmt.Sprintf("the main package \"..\" is replaced with %s", filepath.Dir(b.WorkDir)))
 	}
 
 	for idx, arg := range b.BuildArgs {
 		if arg == "-o" && len(b.BuildArgs) > idx+1 {
 			inputArgs = append(inputArgs[:idx], inputArgs[idx+2:]...)
 			// If binName has been specified.
-			binName = filepath.Base(b.BuildArgs[idx+1])
+			name = filepath.Base(b.BuildArgs[idx+1])
+			notes = append(notes, fmt.Sprintf("\"-o %s\" is removed, the output is in build/; %q is the name of the binary", b.BuildArgs[idx+1], name))
 		}
 	}
 
-	if binName == "" {
-		binName = filepath.Base(lastArg)
+	if name == "" {
+		name = filepath.Base(lastArg)
+	}
+	return
+}
+
+/*
+PrepareArgs returns the args for go build with -o in the build dir, and creates the dir of the binary.
+*/
+func (b *Builder) PrepareArgs(osInfo, archInfo string) (args []string, targetDir, binName string, err error) {
+	args, targetDir, binName, _, err = b.prepareArgs(osInfo, archInfo)
+	if err == nil {
+		os.MkdirAll(targetDir, os.ModePerm)
+	}
+	return
+}
+
+/*
+prepareArgs is PrepareArgs without creating any dir, notes say why the args are rewritten.
+*/
+func (b *Builder) prepareArgs(osInfo, archInfo string) (args []string, targetDir, binName string, notes []string, err error) {
+	inputArgs, name, notes := b.explainMainArgs()
+
+	data := b.nameData(osInfo, archInfo, name)
+	dirName, err := renderTemplate(b.NameTemplates.Dir, DefaultDirTemplate, data)
+	if err != nil {
+		return nil, "", "", nil, fmt.Errorf("invalid dir name template: %w", err)
+	}
+	if binName, err = renderTemplate(b.NameTemplates.Binary, DefaultBinaryTemplate, data); err != nil {
+		return nil, "", "", nil, fmt.Errorf("invalid binary name template: %w", err)
 	}
 
-	targetDir = filepath.Join(b.ProjectDir(), "build", fmt.Sprintf("%s-%s", osInfo, archInfo))
-	os.MkdirAll(targetDir, os.ModePerm)
+	targetDir = filepath.Join(b.ProjectDir(), "build", dirName)
 
 	target := targetDir
 	if binName != "" {
@@ -77,10 +316,11 @@ func (b *Builder) PrepareArgs(osInfo, archInfo string) (args []string, targetDir
 		}
 		target = filepath.Join(targetDir, binName)
 	}
+	notes = append(notes, fmt.Sprintf("\"-o %s\" is added before the main package, from the name templates", target))
 
 	if len(inputArgs) == 1 {
 		inputArgs = append([]string{"-o", target}, inputArgs...)
-		return inputArgs, targetDir, binName
+		return inputArgs, targetDir, binName, notes, nil
 	}
 
 	maxIndex := len(inputArgs) - 1
@@ -88,5 +328,5 @@ func (b *Builder) PrepareArgs(osInfo, archInfo string) (args []string, targetDir
 	// incase overwrite
 	back := append([]string{}, inputArgs[maxIndex:]...)
 	inputArgs = append(append(front, "-o", target), back...)
-	return inputArgs, targetDir, binName
+	return inputArgs, targetDir, binName, notes, nil
 }
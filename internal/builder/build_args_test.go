@@ -0,0 +1,160 @@
+package builder
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExpandSubstitutions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are run by cmd on windows")
+	}
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr string // part of the error, empty for no error.
+	}{
+		{name: "none", s: "-X main.v=1", want: "-X main.v=1"},
+		{name: "quoted args", s: `v=$(echo "a  b" 'c d')`, want: "v=a  b c d"},
+		{name: "nested", s: "$(echo $(echo inner))-x", want: "inner-x"},
+		{name: "two", s: "$(echo a)/$(echo b)", want: "a/b"},
+		{name: "trailing newlines", s: `$(printf 'x\n\n')`, want: "x"},
+		{name: "failing command", s: "v=$(false)", wantErr: "$(false)"},
+		{name: "failing nested command", s: "$(echo $(false))", wantErr: "$(false)"},
+		{name: "unterminated", s: "$(echo a", wantErr: "unterminated"},
+		{name: "empty command", s: "$()", wantErr: "empty command"},
+	}
+	b := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.expandSubstitutions(tt.s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandSubstitutions(%q) error = %v, want %q", tt.s, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expandSubstitutions(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleInjections(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are run by cmd on windows")
+	}
+	args := []string{"-ldflags", "-X main.v=$(echo 1.0)", "./cmd"}
+	if err := New().handleInjections(args); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-ldflags", "-X main.v=1.0", "./cmd"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("handleInjections() = %q, want %q", args, want)
+	}
+}
+
+func TestMergeBuildArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved []string
+		args  []string
+		want  []string
+	}{
+		{
+			name:  "no args",
+			saved: []string{"-ldflags", "-s -w", "./cmd/app"},
+			want:  []string{"-ldflags", "-s -w", "./cmd/app"},
+		},
+		{
+			name:  "flag is replaced",
+			saved: []string{"-ldflags", "-s -w", "-trimpath", "./cmd/app"},
+			args:  []string{"-ldflags", "-X main.v=1"},
+			want:  []string{"-trimpath", "-ldflags", "-X main.v=1", "./cmd/app"},
+		},
+		{
+			name:  "flag with = replaces the spaced one",
+			saved: []string{"-tags", "netgo", "./cmd/app"},
+			args:  []string{"--tags=osusergo"},
+			want:  []string{"--tags=osusergo", "./cmd/app"},
+		},
+		{
+			name:  "new flags are added",
+			saved: []string{"-trimpath", "./cmd/app"},
+			args:  []string{"-race", "-tags", "dev"},
+			want:  []string{"-trimpath", "-race", "-tags", "dev", "./cmd/app"},
+		},
+		{
+			name:  "packages are replaced",
+			saved: []string{"-trimpath", "./cmd/app", "./cmd/other"},
+			args:  []string{"./cmd/cli"},
+			want:  []string{"-trimpath", "./cmd/cli"},
+		},
+		{
+			name:  "value that looks like a package",
+			saved: []string{"-o", "bin/app", "."},
+			args:  []string{"-o", "bin/cli"},
+			want:  []string{"-o", "bin/cli", "."},
+		},
+		{
+			name: "empty saved",
+			args: []string{"-v", "./..."},
+			want: []string{"-v", "./..."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeBuildArgs(tt.saved, tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeBuildArgs(%q, %q) = %q, want %q", tt.saved, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCLIBuildArgs(t *testing.T) {
+	args := []string{"-ldflags", "-X main.Color=#fff -X main.V=#(git describe --tags)", "./cmd#1"}
+	want := []string{"-ldflags", "-X main.Color=#fff -X main.V=$(git describe --tags)", "./cmd#1"}
+	if got := CLIBuildArgs(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("CLIBuildArgs(%q) = %q, want %q", args, got, want)
+	}
+	for s, want := range map[string]bool{"$(date)": true, "#(date)": true, "#fff": false, "$HOME": false} {
+		if got := hasSubstitution(s); got != want {
+			t.Errorf("hasSubstitution(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestMergeArgsNotes(t *testing.T) {
+	b := New()
+	b.BuildArgs = []string{"-ldflags", "-s", "-trimpath", "."}
+	b.MergeArgs([]string{"-ldflags=-w", "-race", "./cmd/app"})
+	wantArgs := []string{"-trimpath", "-ldflags=-w", "-race", "./cmd/app"}
+	if !reflect.DeepEqual(b.BuildArgs, wantArgs) {
+		t.Errorf("BuildArgs = %q, want %q", b.BuildArgs, wantArgs)
+	}
+	wantNotes := []string{
+		`-ldflags of build_args is replaced by ["-ldflags=-w"] of this run`,
+		`["-race"] is added to build_args by this run`,
+		`the packages ["."] of build_args are replaced by ["./cmd/app"] of this run`,
+	}
+	if !reflect.DeepEqual(b.argsNotes, wantNotes) {
+		t.Errorf("notes = %q, want %q", b.argsNotes, wantNotes)
+	}
+
+	b.ReplaceArgs([]string{"./cmd/cli"})
+	if len(b.argsNotes) != 1 || !strings.Contains(b.argsNotes[0], "are replaced by the args of this run") {
+		t.Errorf("notes = %q", b.argsNotes)
+	}
+	b = New()
+	b.ReplaceArgs([]string{"."})
+	if b.argsNotes != nil {
+		t.Errorf("notes without saved build args = %q", b.argsNotes)
+	}
+}
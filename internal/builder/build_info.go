@@ -6,45 +6,132 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/gvcgo/gobuilder/internal/utils"
 	"github.com/gvcgo/goutils/pkgs/gtea/confirm"
-	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/goutils/pkgs/gtea/input"
 	"github.com/gvcgo/goutils/pkgs/gtea/selector"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
 
-func (b *Builder) saveBuilder(buildConfPath string) {
-	buildDir := filepath.Dir(buildConfPath)
-	if ok, _ := gutils.PathIsExist(buildDir); !ok {
-		os.MkdirAll(buildDir, os.ModePerm)
+/*
+InitOptions are the answers for creating the build config file.
+
+Nil answers are asked for in the terminal, unless Defaults is set.
+*/
+type InitOptions struct {
+	Targets          []string
+	BuildArgs        []string
+	EnableCGoWithXGo *bool
+	CGoMode          string
+	XGoImage         string
+	XGoDeps          string
+	XGoDepsArgs      string
+	EnableZip        *bool
+	ArchiveFormat    string
+	EnableUPX        *bool
+	EnableGarble     *bool
+	EnableSign       *bool
+	OsslPfxFilePath  string
+	OsslPfxPassword  string
+	OsslPfxCompany   string
+	OsslPfxWebsite   string
+	MaxParallel      int
+	Defaults         bool // uses the defaults instead of asking.
+	Force            bool // overwrites an existing build config file.
+}
+
+func (o *InitOptions) needsPrompt() bool {
+	if o.Defaults {
+		return false
+	}
+	return len(o.Targets) == 0 ||
+		(o.EnableCGoWithXGo == nil && o.CGoMode == "") ||
+		o.EnableZip == nil ||
+		o.EnableUPX == nil ||
+		o.EnableGarble == nil ||
+		o.EnableSign == nil
+}
+
+/*
+CreateConf creates the build config file of the project in the current working dir.
+*/
+func CreateConf(opts *InitOptions) (confPath string, err error) {
+	b := New()
+	b.cliArgs = opts.BuildArgs
+	confPath = b.ConfPath()
+	if confPath == "" {
+		return "", newStepError("", StepConfig, ErrProjectNotFound)
+	}
+	if ok, _ := gutils.PathIsExist(confPath); ok && !opts.Force {
+		return "", newStepError("", StepConfig, fmt.Errorf("%w: %s", ErrConfExists, confPath))
+	}
+	return confPath, newStepError("", StepConfig, b.saveBuilder(confPath, opts))
+}
+
+func (b *Builder) saveBuilder(buildConfPath string, opts *InitOptions) error {
+	if opts.needsPrompt() && !utils.IsInteractive() {
+		return ErrNotInteractive
 	}
 
 	// Choose target Os/Arch
-	b.chooseArchOs()
+	if len(opts.Targets) > 0 {
+		// nothing is written for invalid targets.
+		if err := CheckTargets(opts.Targets); err != nil {
+			return err
+		}
+		b.ArchOSList = append([]string{}, opts.Targets...)
+	} else if opts.Defaults {
+		b.ArchOSList = []string{fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)}
+	} else {
+		b.chooseArchOs()
+	}
 
 	if len(b.ArchOSList) == 0 {
-		gprint.PrintError("No target Os/Arch chosen.")
-		os.Exit(1)
+		return ErrNoTarget
 	}
 
 	// Enable CGO with xgo or not.
-	b.enableCGO()
+	if !IsValidCGoMode(opts.CGoMode) {
+		return fmt.Errorf("%w: %s", ErrCGoMode, opts.CGoMode)
+	}
+	b.CGoMode = opts.CGoMode
+	if b.CGoMode == "" && answer(opts.EnableCGoWithXGo, opts.Defaults, "To enable CGO with [xgo] or not?") {
+		b.CGoMode = CGoModeXgo
+	}
+	b.XGoImage = opts.XGoImage
+	b.XGoDeps = opts.XGoDeps
+	b.XGoDepsArgs = opts.XGoDepsArgs
 
 	// Enable zip after compilation.
-	b.enableZip()
+	b.EnableZip = answer(opts.EnableZip, opts.Defaults, "To zip binaries or not?")
+	if !IsValidArchiveFormat(opts.ArchiveFormat) {
+		return fmt.Errorf("%w: %s", ErrArchiveFormat, opts.ArchiveFormat)
+	}
+	b.ArchiveFormat = opts.ArchiveFormat
 
 	// Enable upx or not.
-	b.enableUpx()
+	b.EnableUPX = answer(opts.EnableUPX, opts.Defaults, "To pack binaries with UPX or not?")
 
 	// Enable garble or not.
-	b.enableGarble()
+	b.EnableGarble = answer(opts.EnableGarble, opts.Defaults, "Use garble to obfuscate for the binaries or not?")
 
 	// Enable osslsigncode or not.
-	b.enableOsslsigncode()
+	if opts.EnableSign != nil || opts.Defaults {
+		b.EnableOsslsigncode = opts.EnableSign != nil && *opts.EnableSign && opts.OsslPfxFilePath != ""
+		b.OsslPfxFilePath = opts.OsslPfxFilePath
+		b.OsslPfxPassword = opts.OsslPfxPassword
+		if b.EnableOsslsigncode && b.OsslPfxPassword == "" {
+			b.OsslPfxPassword = DefaultPfxPasswordRef
+		}
+		b.OsslPfxCompany = opts.OsslPfxCompany
+		b.OsslPfxWebsite = opts.OsslPfxWebsite
+	} else {
+		b.enableOsslsigncode()
+	}
+
+	b.MaxParallel = opts.MaxParallel
 
 	// process build args.
 	b.processArgs()
@@ -53,13 +140,35 @@ func (b *Builder) saveBuilder(buildConfPath string) {
 	b.processWorkDir()
 
 	// save conf file.
-	b.saveConf(buildConfPath)
+	buildDir := filepath.Dir(buildConfPath)
+	if ok, _ := gutils.PathIsExist(buildDir); !ok {
+		os.MkdirAll(buildDir, os.ModePerm)
+	}
+	return b.saveConf(buildConfPath)
+}
+
+/*
+answer returns the given answer, or asks in the terminal if there is none.
+*/
+func answer(given *bool, useDefault bool, prompt string) bool {
+	if given != nil {
+		return *given
+	}
+	if useDefault {
+		return false
+	}
+	cfm := confirm.NewConfirmation(confirm.WithPrompt(prompt))
+	cfm.Run()
+	return cfm.Result()
 }
 
 func (b *Builder) chooseArchOs() {
 	items := selector.NewItemList()
 	items.Add("Current Os/Arch Only", fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH))
 	items.Add("Frequently Used Os/Arch", "frequently")
+	for _, variant := range utils.GetCommonVariants() {
+		items.Add(variant, variant)
+	}
 	others := utils.GetOtherArchOS()
 	for _, osArch := range others {
 		if osArch != "" {
@@ -91,30 +200,6 @@ func (b *Builder) chooseArchOs() {
 	}
 }
 
-func (b *Builder) enableCGO() {
-	cfm := confirm.NewConfirmation(confirm.WithPrompt("To enable CGO with [xgo] or not?"))
-	cfm.Run()
-	b.EnableCGoWithXGo = cfm.Result()
-}
-
-func (b *Builder) enableZip() {
-	cfm := confirm.NewConfirmation(confirm.WithPrompt("To zip binaries or not?"))
-	cfm.Run()
-	b.EnableZip = cfm.Result()
-}
-
-func (b *Builder) enableUpx() {
-	cfm := confirm.NewConfirmation(confirm.WithPrompt("To pack binaries with UPX or not?"))
-	cfm.Run()
-	b.EnableUPX = cfm.Result()
-}
-
-func (b *Builder) enableGarble() {
-	cfm := confirm.NewConfirmation(confirm.WithPrompt("Use garble to obfuscate for the binaries or not?"))
-	cfm.Run()
-	b.EnableGarble = cfm.Result()
-}
-
 func (b *Builder) enableOsslsigncode() {
 	cfm := confirm.NewConfirmation(confirm.WithPrompt("Use osslsigncode to sign the binaries or not?"))
 	cfm.Run()
@@ -124,12 +209,14 @@ func (b *Builder) enableOsslsigncode() {
 		mInput := input.NewMultiInput()
 		var (
 			pfxFilePath string = "Pfx file path"
-			pfxPassword string = "Pfx password"
+			pfxPassword string = "Pfx password (env:VAR, file:/path or cmd:command)"
 			pfxCompany  string = "Pfx company"
 			pfxWebsite  string = "Pfx website"
 		)
 		mInput.AddOneItem(pfxFilePath, input.MWithWidth(120))
-		mInput.AddOneItem(pfxPassword, input.MWithWidth(60), input.MWithEchoMode(textinput.EchoPassword), input.MWithEchoChar("*"))
+		// Store a reference instead of the password, build.json is easily committed.
+		mInput.AddOneItem(pfxPassword, input.MWithWidth(60), input.MWithDefaultValue(DefaultPfxPasswordRef),
+			input.MWithEchoMode(textinput.EchoPassword), input.MWithEchoChar("*"))
 		mInput.AddOneItem(pfxCompany, input.MWithWidth(60))
 		mInput.AddOneItem(pfxWebsite, input.MWithWidth(120))
 		mInput.Run()
@@ -146,20 +233,21 @@ func (b *Builder) enableOsslsigncode() {
 }
 
 func (b *Builder) processArgs() {
-	args := []string{}
-	if len(os.Args) > 2 {
-		args = os.Args[2:]
-	}
-	if len(args) == 0 {
+	if len(b.cliArgs) == 0 {
 		return
 	}
+	b.BuildArgs = CLIBuildArgs(b.cliArgs)
+}
 
+/*
+CLIBuildArgs converts the go build args from the command line, "#(...)" is "$(...)" without the expansion of the shell.
+*/
+func CLIBuildArgs(args []string) []string {
+	result := make([]string, len(args))
 	for idx, v := range args {
-		v = strings.ReplaceAll(v, "#", "$")
-		args[idx] = v
+		result[idx] = hashSubstitutions(v)
 	}
-
-	b.BuildArgs = args
+	return result
 }
 
 func (b *Builder) processWorkDir() {
@@ -167,9 +255,10 @@ func (b *Builder) processWorkDir() {
 	b.WorkDir = cwd
 }
 
-func (b *Builder) saveConf(buildConfPath string) {
-	data, _ := json.MarshalIndent(b, "", "    ")
-	if len(data) > 0 {
-		os.WriteFile(buildConfPath, data, os.ModePerm)
+func (b *Builder) saveConf(buildConfPath string) error {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(buildConfPath, data, os.ModePerm)
 }
@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inProject runs the test in a new go project.
+func inProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+	return dir
+}
+
+func TestCreateConfTargets(t *testing.T) {
+	for _, target := range []string{"linux/z80", "linux", "linux/amd64/v3/x"} {
+		t.Run(target, func(t *testing.T) {
+			dir := inProject(t)
+			_, err := CreateConf(&InitOptions{Targets: []string{"linux/amd64", target}, Defaults: true})
+			if errors.Is(err, ErrGoNotInstalled) {
+				t.Skip("go is not installed")
+			}
+			if !errors.Is(err, ErrInvalidTarget) {
+				t.Fatalf("CreateConf() error = %v, want %v", err, ErrInvalidTarget)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "build")); !os.IsNotExist(err) {
+				t.Errorf("build dir is created for an invalid target")
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dir := inProject(t)
+		confPath, err := CreateConf(&InitOptions{Targets: []string{"linux/amd64", "linux/arm/v7"}, Defaults: true})
+		if errors.Is(err, ErrGoNotInstalled) {
+			t.Skip("go is not installed")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confPath != filepath.Join(dir, "build", ConfFileName) {
+			t.Errorf("confPath = %s", confPath)
+		}
+	})
+}
@@ -0,0 +1,378 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+CacheDirEnv sets the cache dir, the default is gber in the user cache dir, like ~/.cache/gber.
+*/
+const CacheDirEnv = "GBER_CACHE_DIR"
+
+const cacheEntryFileName = "entry.json"
+
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gber"), nil
+}
+
+/*
+cacheEntry is the artifacts of a target in the cache, paths are relative to the build dir.
+*/
+type cacheEntry struct {
+	Binary  string `json:"binary"`
+	Archive string `json:"archive,omitempty"`
+}
+
+/*
+hashSources hashes the files of the project, except build/ and .git.
+*/
+func hashSources(projectDir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(projectDir, func(fPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(projectDir, fPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel == "build" || info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(fPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(h, "%s\x00%o\x00", filepath.ToSlash(rel), info.Mode().Perm())
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var (
+	toolVersions   = map[string]string{}
+	toolVersionsMu sync.Mutex
+)
+
+/*
+toolVersion returns the output of a version command, once per process.
+*/
+func toolVersion(args ...string) string {
+	key := strings.Join(args, " ")
+	toolVersionsMu.Lock()
+	defer toolVersionsMu.Unlock()
+	if v, ok := toolVersions[key]; ok {
+		return v
+	}
+	buf, err := gutils.ExecuteSysCommand(true, "", args...)
+	v := ""
+	if err == nil {
+		v = strings.TrimSpace(buf.String())
+	}
+	toolVersions[key] = v
+	return v
+}
+
+/*
+cacheEnv returns the env vars that change the build: GO*, CGO_*, the C compilers, and the env in build.json.
+*/
+func (b *Builder) cacheEnv(env []string) []string {
+	custom := map[string]bool{"CC": true, "CXX": true, "AR": true, "PKG_CONFIG": true, "SOURCE_DATE_EPOCH": true}
+	for k := range b.Env {
+		custom[k] = true
+	}
+	if b.override != nil {
+		for k := range b.override.Env {
+			custom[k] = true
+		}
+	}
+	// the last value of a key wins.
+	values := map[string]string{}
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		if strings.HasPrefix(k, "GO") || strings.HasPrefix(k, "CGO_") || custom[k] {
+			values[k] = v
+		}
+	}
+	result := make([]string, 0, len(values))
+	for k, v := range values {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+/*
+cacheKey hashes everything that changes the artifacts of the target, b is the copy of the target from forTarget.
+
+Only the fields of this target are hashed, so narrowing the targets with --targets,
+or editing the overrides of the other targets, keeps the cache.
+*/
+func (b *Builder) cacheKey(target string, args, env []string) (string, error) {
+	pipeline := []*PipelineStep{}
+	for _, step := range b.Pipeline {
+		if step != nil && step.appliesTo(target) {
+			pipeline = append(pipeline, &PipelineStep{Step: step.Step, Options: step.Options})
+		}
+	}
+	conf := map[string]any{
+		"override":            b.override,
+		"enable_zip":          b.EnableZip,
+		"enable_garble":       b.EnableGarble,
+		"enable_upx":          b.EnableUPX,
+		"enable_osslsigncode": b.EnableOsslsigncode,
+		"ossl_pfx_file_path":  b.OsslPfxFilePath,
+		"ossl_pfx_company":    b.OsslPfxCompany,
+		"ossl_pfx_website":    b.OsslPfxWebsite,
+		"pipeline":            pipeline,
+		"name_templates":      b.NameTemplates,
+		"os_aliases":          b.OsAliases,
+		"arch_aliases":        b.ArchAliases,
+		"archive_format":      b.ArchiveFormat,
+		"archive_files":       b.ArchiveFiles,
+		"archive_wrap_dir":    b.ArchiveWrapDir,
+		"cgo_mode":            b.cgoMode(),
+		"xgo_image":           b.XGoImage,
+		"xgo_deps":            b.XGoDeps,
+		"xgo_deps_args":       b.XGoDepsArgs,
+		"reproducible":        b.Reproducible,
+	}
+	tools := map[string]string{}
+	if b.EnableGarble {
+		tools["garble"] = toolVersion("garble", "version")
+	}
+	if b.EnableUPX {
+		tools["upx"] = toolVersion("upx", "--version")
+	}
+	if b.EnableOsslsigncode {
+		tools["osslsigncode"] = toolVersion("osslsigncode", "--version")
+	}
+	if b.cgoMode() == CGoModeZig {
+		tools["zig"] = toolVersion("zig", "version")
+	}
+	data, err := json.Marshal(map[string]any{
+		"sources":    b.sourceHash,
+		"go_version": b.vars.GoVersion,
+		"target":     target,
+		"args":       args,
+		"env":        b.cacheEnv(env),
+		"conf":       conf,
+		"tools":      tools,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+/*
+targetCacheKey returns the cache key of the target, empty if the cache is disabled.
+*/
+func (b *Builder) targetCacheKey(target string, args, env []string) string {
+	if b.sourceHash == "" {
+		return ""
+	}
+	key, err := b.cacheKey(target, args, env)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+func copyFileTo(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	fw, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+	return copyFile(fw, src)
+}
+
+/*
+restoreCache copies the cached artifacts to the build dir, ok is false if there is no cache for the key.
+*/
+func (b *Builder) restoreCache(key string) (binary, archive string, ok bool) {
+	if key == "" {
+		return "", "", false
+	}
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return "", "", false
+	}
+	entryDir := filepath.Join(cacheDir, key)
+	data, err := os.ReadFile(filepath.Join(entryDir, cacheEntryFileName))
+	if err != nil {
+		return "", "", false
+	}
+	entry := &cacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil || entry.Binary == "" {
+		return "", "", false
+	}
+	buildDir := filepath.Join(b.ProjectDir(), "build")
+	binary = filepath.Join(buildDir, filepath.FromSlash(entry.Binary))
+	if err = copyFileTo(filepath.Join(entryDir, "binary"), binary); err != nil {
+		return "", "", false
+	}
+	if entry.Archive != "" {
+		archive = filepath.Join(buildDir, filepath.FromSlash(entry.Archive))
+		if err = copyFileTo(filepath.Join(entryDir, "archive"), archive); err != nil {
+			return "", "", false
+		}
+	}
+	// for pruning by age.
+	now := time.Now()
+	os.Chtimes(entryDir, now, now)
+	return binary, archive, true
+}
+
+/*
+saveCache stores the artifacts of a target, a failure only skips caching.
+*/
+func (b *Builder) saveCache(key, binary, archive string) error {
+	if key == "" {
+		return nil
+	}
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(cacheDir, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	buildDir := filepath.Join(b.ProjectDir(), "build")
+	entry := &cacheEntry{}
+	for _, f := range []struct {
+		src  string
+		name string
+		rel  *string
+	}{
+		{binary, "binary", &entry.Binary},
+		{archive, "archive", &entry.Archive},
+	} {
+		if f.src == "" {
+			continue
+		}
+		rel, err := filepath.Rel(buildDir, f.src)
+		if err != nil {
+			return err
+		}
+		*f.rel = filepath.ToSlash(rel)
+		if err = copyFileTo(f.src, filepath.Join(tempDir, f.name)); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(tempDir, cacheEntryFileName), data, os.ModePerm); err != nil {
+		return err
+	}
+	entryDir := filepath.Join(cacheDir, key)
+	os.RemoveAll(entryDir)
+	return os.Rename(tempDir, entryDir)
+}
+
+/*
+CacheInfo returns the number of entries in the cache dir, and their size.
+*/
+func CacheInfo() (dir string, entries int, size int64, err error) {
+	if dir, err = CacheDir(); err != nil {
+		return
+	}
+	if ok, _ := gutils.PathIsExist(dir); !ok {
+		return dir, 0, 0, nil
+	}
+	dList, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, d := range dList {
+		if d.IsDir() && !strings.HasPrefix(d.Name(), "tmp-") {
+			entries++
+		}
+	}
+	size, err = utils.DirSize(dir)
+	return
+}
+
+/*
+PruneCache removes the entries not used for the duration, all entries if olderThan is 0.
+*/
+func PruneCache(olderThan time.Duration) (removed int, err error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return 0, err
+	}
+	if ok, _ := gutils.PathIsExist(dir); !ok {
+		return 0, nil
+	}
+	dList, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range dList {
+		if !d.IsDir() {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return removed, err
+		}
+		if olderThan > 0 && time.Since(info.ModTime()) < olderThan {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(dir, d.Name())); err != nil {
+			return removed, err
+		}
+		if !strings.HasPrefix(d.Name(), "tmp-") {
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,45 @@
+package builder
+
+import "testing"
+
+func TestCacheKeyOfNarrowedTargets(t *testing.T) {
+	newBuilder := func(targets ...string) *Builder {
+		b := New()
+		b.ArchOSList = targets
+		b.vars = &BuildVars{GoVersion: "go1.22.0"}
+		b.sourceHash = "sources"
+		b.Overrides = map[string]*TargetOverride{
+			"linux/amd64": {Tags: []string{"netgo"}},
+		}
+		b.Pipeline = []*PipelineStep{{Step: StepArchive}, {Step: "notify", Targets: []string{"windows/*"}}}
+		return b
+	}
+	key := func(b *Builder, target string) string {
+		result := &TargetResult{Target: target, Os: "linux", Arch: "amd64"}
+		k, err := b.forTarget(result).cacheKey(target, []string{"go", "build", "."}, []string{"GOOS=linux"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	full := newBuilder("linux/amd64", "windows/amd64", "darwin/arm64")
+	full.Hooks.BeforeEach = []string{"echo hi"}
+	narrowed := newBuilder("linux/amd64")
+	narrowed.Overrides["windows/amd64"] = &TargetOverride{Ldflags: "-H windowsgui"}
+	narrowed.Pipeline[1].Options = map[string]any{"url": "https://example.com"}
+	if key(full, "linux/amd64") != key(narrowed, "linux/amd64") {
+		t.Error("the cache key changes with the other targets")
+	}
+
+	changed := newBuilder("linux/amd64")
+	changed.Overrides["linux/amd64"].Tags = []string{"osusergo"}
+	if key(full, "linux/amd64") == key(changed, "linux/amd64") {
+		t.Error("the cache key does not change with the override of the target")
+	}
+	changed = newBuilder("linux/amd64")
+	changed.EnableZip = true
+	if key(full, "linux/amd64") == key(changed, "linux/amd64") {
+		t.Error("the cache key does not change with enable_zip")
+	}
+}
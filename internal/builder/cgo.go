@@ -0,0 +1,147 @@
+package builder
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+/*
+CGO modes.
+
+CGoModeNative uses the cross gcc on PATH, like aarch64-linux-gnu-gcc.
+CGoModeZig uses "zig cc" as the C compiler for all targets.
+CGoModeXgo uses xgo with docker.
+*/
+const (
+	CGoModeNative string = "native"
+	CGoModeZig    string = "zig"
+	CGoModeXgo    string = "xgo"
+)
+
+func IsValidCGoMode(mode string) bool {
+	switch mode {
+	case "", CGoModeNative, CGoModeZig, CGoModeXgo:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+gccTriples are the prefixes of the cross gcc for the targets, like Debian's gcc-aarch64-linux-gnu.
+*/
+var gccTriples = map[string]string{
+	"linux/amd64":    "x86_64-linux-gnu",
+	"linux/386":      "i686-linux-gnu",
+	"linux/arm64":    "aarch64-linux-gnu",
+	"linux/arm":      "arm-linux-gnueabihf",
+	"linux/riscv64":  "riscv64-linux-gnu",
+	"linux/ppc64le":  "powerpc64le-linux-gnu",
+	"linux/s390x":    "s390x-linux-gnu",
+	"linux/mips64le": "mips64el-linux-gnuabi64",
+	"linux/mipsle":   "mipsel-linux-gnu",
+	"windows/amd64":  "x86_64-w64-mingw32",
+	"windows/386":    "i686-w64-mingw32",
+}
+
+/*
+zigTriples are the targets of "zig cc".
+*/
+var zigTriples = map[string]string{
+	"linux/amd64":   "x86_64-linux-gnu",
+	"linux/386":     "x86-linux-gnu",
+	"linux/arm64":   "aarch64-linux-gnu",
+	"linux/arm":     "arm-linux-gnueabihf",
+	"linux/riscv64": "riscv64-linux-gnu",
+	"linux/ppc64le": "powerpc64le-linux-gnu",
+	"linux/s390x":   "s390x-linux-gnu",
+	"windows/amd64": "x86_64-windows-gnu",
+	"windows/386":   "x86-windows-gnu",
+	"windows/arm64": "aarch64-windows-gnu",
+	"darwin/amd64":  "x86_64-macos",
+	"darwin/arm64":  "aarch64-macos",
+}
+
+/*
+cgoMode returns the CGO mode, enable_cgo_with_xgo is the same as "xgo".
+*/
+func (b *Builder) cgoMode() string {
+	if b.CGoMode == "" && b.EnableCGoWithXGo {
+		return CGoModeXgo
+	}
+	return b.CGoMode
+}
+
+/*
+hasCC reports whether CC is set in the env of build.json for the target.
+*/
+func (b *Builder) hasCC(target string) bool {
+	if _, ok := b.Env["CC"]; ok {
+		return true
+	}
+	ov := b.override
+	if ov == nil {
+		ov = b.targetOverride(target)
+	}
+	if ov != nil {
+		_, ok := ov.Env["CC"]
+		return ok
+	}
+	return false
+}
+
+/*
+cgoEnv returns CGO_ENABLED=1 and the C compilers for the native and the zig modes.
+*/
+func (b *Builder) cgoEnv(osInfo, archInfo string) ([]string, error) {
+	target := osInfo + "/" + archInfo
+	mode := b.cgoMode()
+	if (mode == CGoModeNative || mode == CGoModeZig) && b.hasCC(target) {
+		// the C compiler is from env.
+		return []string{"CGO_ENABLED=1"}, nil
+	}
+	switch mode {
+	case CGoModeNative:
+		if osInfo == runtime.GOOS && archInfo == runtime.GOARCH {
+			// the default C compiler.
+			return []string{"CGO_ENABLED=1"}, nil
+		}
+		triple, ok := gccTriples[target]
+		if !ok {
+			return nil, fmt.Errorf("no cross gcc is known for %s, set CC in env", target)
+		}
+		if archInfo == "arm" && (b.variant == "v5" || b.variant == "5") {
+			// soft float.
+			triple = "arm-linux-gnueabi"
+		}
+		cc := triple + "-gcc"
+		if _, err := exec.LookPath(cc); err != nil {
+			if err = b.missingTool(cc); err != nil {
+				return nil, err
+			}
+		}
+		env := []string{"CGO_ENABLED=1", "CC=" + cc}
+		if _, err := exec.LookPath(triple + "-g++"); err == nil {
+			env = append(env, "CXX="+triple+"-g++")
+		}
+		return env, nil
+	case CGoModeZig:
+		triple, ok := zigTriples[target]
+		if !ok {
+			return nil, fmt.Errorf("no zig target is known for %s, set CC in env", target)
+		}
+		if _, err := exec.LookPath("zig"); err != nil {
+			if err = b.missingTool("zig"); err != nil {
+				return nil, err
+			}
+		}
+		return []string{
+			"CGO_ENABLED=1",
+			fmt.Sprintf("CC=zig cc -target %s", triple),
+			fmt.Sprintf("CXX=zig c++ -target %s", triple),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,324 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+/*
+Version of the build config file.
+
+Bump it when fields of Builder are renamed or removed, and add a migration for the old version.
+*/
+const ConfVersion int = 3
+
+/*
+Migrations upgrade a config from the version of the key to the next version,
+the profiles in the config included.
+
+Version 1 has no version field, and needs no migration besides adding it.
+*/
+var migrations = map[int]func(conf map[string]any){
+	2: migrateV2,
+}
+
+/*
+Version 2 enables CGO with xgo by enable_cgo_with_xgo, version 3 by cgo_mode "xgo".
+
+The profiles are migrated with the shared defaults, they inherit both fields.
+Conflicting fields in the same object are kept, `gber config validate` reports them.
+*/
+func migrateV2(conf map[string]any) {
+	topXgo, _ := conf["enable_cgo_with_xgo"].(bool)
+	topMode, _ := conf["cgo_mode"].(string)
+	topConflict := xgoConflict(conf, "") != ""
+	newTopMode := topMode
+	if !topConflict && topXgo && topMode == "" {
+		newTopMode = CGoModeXgo
+	}
+
+	profiles, _ := conf["profiles"].([]any)
+	for _, p := range profiles {
+		profile, ok := p.(map[string]any)
+		if !ok || xgoConflict(profile, topMode) != "" {
+			continue
+		}
+		xgo, mode := topXgo, topMode
+		if v, ok := profile["enable_cgo_with_xgo"].(bool); ok {
+			xgo = v
+		}
+		inherited := newTopMode
+		if v, ok := profile["cgo_mode"].(string); ok {
+			mode, inherited = v, v
+		}
+		if mode == "" && xgo {
+			mode = CGoModeXgo
+		}
+		delete(profile, "enable_cgo_with_xgo")
+		if mode != inherited {
+			profile["cgo_mode"] = mode
+		}
+	}
+
+	if topConflict {
+		return
+	}
+	delete(conf, "enable_cgo_with_xgo")
+	if newTopMode != topMode {
+		conf["cgo_mode"] = newTopMode
+	}
+}
+
+/*
+xgoConflict returns the cgo_mode conflicting with enable_cgo_with_xgo in the same object,
+inherited is the cgo_mode of the shared defaults for a profile.
+*/
+func xgoConflict(conf map[string]any, inherited string) string {
+	if xgo, _ := conf["enable_cgo_with_xgo"].(bool); !xgo {
+		return ""
+	}
+	mode := inherited
+	if v, ok := conf["cgo_mode"].(string); ok {
+		mode = v
+	}
+	if mode == "" || mode == CGoModeXgo {
+		return ""
+	}
+	return mode
+}
+
+func confVersion(conf map[string]any) (int, error) {
+	v, ok := conf["version"]
+	if !ok {
+		return 1, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 1 || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid version: %v", v)
+	}
+	return int(f), nil
+}
+
+/*
+migrateConf upgrades the content of a build config file to ConfVersion.
+The changes are returned as lines of a diff.
+*/
+func migrateConf(data []byte) (newData []byte, changes []string, err error) {
+	conf := map[string]any{}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return nil, nil, err
+	}
+	version, err := confVersion(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > ConfVersion {
+		return nil, nil, fmt.Errorf("%w: version %d, supported: %d", ErrConfTooNew, version, ConfVersion)
+	}
+	if version == ConfVersion {
+		return data, nil, nil
+	}
+
+	old := map[string]any{}
+	json.Unmarshal(data, &old)
+
+	for ; version < ConfVersion; version++ {
+		if migrate, ok := migrations[version]; ok {
+			migrate(conf)
+		}
+	}
+	conf["version"] = ConfVersion
+
+	changes = diffConf("", old, conf)
+	newData, err = marshalConf(data, conf)
+	return
+}
+
+/*
+marshalConf encodes conf like json.MarshalIndent, but keeps the key order of data,
+the original content of the file, for the top level config and the profiles.
+A new version is put first, other new keys are appended in alphabetical order.
+*/
+func marshalConf(data []byte, conf map[string]any) ([]byte, error) {
+	keys, raw, err := objectKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	profileKeys := [][]string{}
+	rawProfiles := []json.RawMessage{}
+	json.Unmarshal(raw["profiles"], &rawProfiles)
+	for _, p := range rawProfiles {
+		k, _, _ := objectKeys(p)
+		profileKeys = append(profileKeys, k)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = writeObject(buf, conf, keys, profileKeys, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// objectKeys returns the keys of a JSON object in order, and their values.
+func objectKeys(data []byte) (keys []string, values map[string]json.RawMessage, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t != json.Delim('{') {
+		return nil, nil, fmt.Errorf("not a JSON object: %v", t)
+	}
+	values = map[string]json.RawMessage{}
+	for dec.More() {
+		if t, err = dec.Token(); err != nil {
+			return nil, nil, err
+		}
+		key, _ := t.(string)
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	return keys, values, nil
+}
+
+func writeObject(buf *bytes.Buffer, obj map[string]any, keys []string, profileKeys [][]string, indent string) error {
+	ordered, seen := []string{}, map[string]bool{}
+	add := func(k string) {
+		if _, ok := obj[k]; ok && !seen[k] {
+			seen[k] = true
+			ordered = append(ordered, k)
+		}
+	}
+	add("version")
+	for _, k := range keys {
+		add(k)
+	}
+	added := []string{}
+	for k := range obj {
+		if !seen[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	for _, k := range added {
+		add(k)
+	}
+	if len(ordered) == 0 {
+		buf.WriteString("{}")
+		return nil
+	}
+
+	inner := indent + "    "
+	buf.WriteString("{")
+	for idx, k := range ordered {
+		if idx > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(k)
+		buf.WriteString("\n" + inner + string(key) + ": ")
+		if profiles, ok := obj[k].([]any); ok && k == "profiles" && profileKeys != nil && len(profiles) > 0 {
+			buf.WriteString("[")
+			for pIdx, p := range profiles {
+				if pIdx > 0 {
+					buf.WriteString(",")
+				}
+				buf.WriteString("\n" + inner + "    ")
+				profile, ok := p.(map[string]any)
+				if !ok {
+					value, err := json.MarshalIndent(p, inner+"    ", "    ")
+					if err != nil {
+						return err
+					}
+					buf.Write(value)
+					continue
+				}
+				var pKeys []string
+				if pIdx < len(profileKeys) {
+					pKeys = profileKeys[pIdx]
+				}
+				if err := writeObject(buf, profile, pKeys, nil, inner+"    "); err != nil {
+					return err
+				}
+			}
+			buf.WriteString("\n" + inner + "]")
+			continue
+		}
+		value, err := json.MarshalIndent(obj[k], inner, "    ")
+		if err != nil {
+			return err
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("\n" + indent + "}")
+	return nil
+}
+
+/*
+diffConf lists the added, removed and changed keys.
+*/
+func diffConf(prefix string, old, new map[string]any) (lines []string) {
+	keys := map[string]struct{}{}
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+	sorted := []string{}
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		oldValue, inOld := old[k]
+		newValue, inNew := new[k]
+		oldProfiles, ok1 := oldValue.([]any)
+		newProfiles, ok2 := newValue.([]any)
+		if k == "profiles" && prefix == "" && ok1 && ok2 && len(oldProfiles) == len(newProfiles) {
+			for idx := range newProfiles {
+				o, _ := oldProfiles[idx].(map[string]any)
+				n, _ := newProfiles[idx].(map[string]any)
+				lines = append(lines, diffConf(fmt.Sprintf("profiles[%d].", idx), o, n)...)
+			}
+			continue
+		}
+
+		oldStr, _ := json.Marshal(oldValue)
+		newStr, _ := json.Marshal(newValue)
+		switch {
+		case !inNew:
+			lines = append(lines, fmt.Sprintf("- %s%s: %s", prefix, k, oldStr))
+		case !inOld:
+			lines = append(lines, fmt.Sprintf("+ %s%s: %s", prefix, k, newStr))
+		case string(oldStr) != string(newStr):
+			lines = append(lines, fmt.Sprintf("- %s%s: %s", prefix, k, oldStr))
+			lines = append(lines, fmt.Sprintf("+ %s%s: %s", prefix, k, newStr))
+		}
+	}
+	return
+}
+
+/*
+MigrateConfFile upgrades a build config file in place.
+*/
+func MigrateConfFile(buildConf string) (changes []string, err error) {
+	data, err := os.ReadFile(buildConf)
+	if err != nil {
+		return nil, err
+	}
+	newData, changes, err := migrateConf(data)
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+	return changes, os.WriteFile(buildConf, newData, os.ModePerm)
+}
@@ -0,0 +1,312 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateV2(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		want string
+	}{
+		{
+			name: "xgo becomes cgo_mode",
+			conf: `{"enable_cgo_with_xgo": true}`,
+			want: `{"cgo_mode": "xgo"}`,
+		},
+		{
+			name: "empty cgo_mode becomes xgo",
+			conf: `{"enable_cgo_with_xgo": true, "cgo_mode": ""}`,
+			want: `{"cgo_mode": "xgo"}`,
+		},
+		{
+			name: "disabled xgo is dropped",
+			conf: `{"enable_cgo_with_xgo": false, "cgo_mode": "zig"}`,
+			want: `{"cgo_mode": "zig"}`,
+		},
+		{
+			name: "same mode is merged",
+			conf: `{"enable_cgo_with_xgo": true, "cgo_mode": "xgo"}`,
+			want: `{"cgo_mode": "xgo"}`,
+		},
+		{
+			name: "conflict is kept",
+			conf: `{"enable_cgo_with_xgo": true, "cgo_mode": "native"}`,
+			want: `{"enable_cgo_with_xgo": true, "cgo_mode": "native"}`,
+		},
+		{
+			name: "profile inherits xgo",
+			conf: `{"enable_cgo_with_xgo": true, "profiles": [{"name": "a"}]}`,
+			want: `{"cgo_mode": "xgo", "profiles": [{"name": "a"}]}`,
+		},
+		{
+			name: "profile disables inherited xgo",
+			conf: `{"enable_cgo_with_xgo": true, "profiles": [{"name": "a", "enable_cgo_with_xgo": false}]}`,
+			want: `{"cgo_mode": "xgo", "profiles": [{"name": "a", "cgo_mode": ""}]}`,
+		},
+		{
+			name: "profile with empty cgo_mode inherits xgo",
+			conf: `{"enable_cgo_with_xgo": true, "profiles": [{"name": "a", "cgo_mode": ""}]}`,
+			want: `{"cgo_mode": "xgo", "profiles": [{"name": "a", "cgo_mode": "xgo"}]}`,
+		},
+		{
+			name: "profile overrides inherited xgo",
+			conf: `{"enable_cgo_with_xgo": true, "profiles": [{"name": "a", "cgo_mode": "zig"}]}`,
+			want: `{"cgo_mode": "xgo", "profiles": [{"name": "a", "cgo_mode": "zig"}]}`,
+		},
+		{
+			name: "profile enables xgo",
+			conf: `{"profiles": [{"name": "a", "enable_cgo_with_xgo": true}]}`,
+			want: `{"profiles": [{"name": "a", "cgo_mode": "xgo"}]}`,
+		},
+		{
+			name: "profile keeps inherited mode",
+			conf: `{"cgo_mode": "zig", "profiles": [{"name": "a", "enable_cgo_with_xgo": false}]}`,
+			want: `{"cgo_mode": "zig", "profiles": [{"name": "a"}]}`,
+		},
+		{
+			name: "profile conflict is kept",
+			conf: `{"cgo_mode": "native", "profiles": [{"name": "a", "enable_cgo_with_xgo": true}]}`,
+			want: `{"cgo_mode": "native", "profiles": [{"name": "a", "enable_cgo_with_xgo": true}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, want := map[string]any{}, map[string]any{}
+			if err := json.Unmarshal([]byte(tt.conf), &conf); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			migrateV2(conf)
+			if !reflect.DeepEqual(conf, want) {
+				got, _ := json.Marshal(conf)
+				t.Errorf("migrateV2(%s) = %s, want %s", tt.conf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateXgoConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		want string // part of the error, empty for no conflict.
+	}{
+		{
+			name: "no conflict",
+			conf: `{"enable_cgo_with_xgo": true, "cgo_mode": "xgo"}`,
+		},
+		{
+			name: "conflict",
+			conf: `{"enable_cgo_with_xgo": true, "cgo_mode": "native"}`,
+			want: `enable_cgo_with_xgo is true but cgo_mode is "native"`,
+		},
+		{
+			name: "profile conflict",
+			conf: `{"cgo_mode": "zig", "profiles": [{"name": "a", "enable_cgo_with_xgo": true}]}`,
+			want: `enable_cgo_with_xgo is true but cgo_mode is "zig" in profiles[0]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildConf := filepath.Join(t.TempDir(), "build.json")
+			if err := os.WriteFile(buildConf, []byte(tt.conf), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			issues, err := ValidateConfFile(buildConf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := ""
+			for _, issue := range issues {
+				if issue.Level == IssueError && strings.Contains(issue.Message, "enable_cgo_with_xgo") {
+					found = issue.Message
+				}
+			}
+			switch {
+			case tt.want == "" && found != "":
+				t.Errorf("unexpected issue: %s", found)
+			case tt.want != "" && !strings.Contains(found, tt.want):
+				t.Errorf("issue = %q, want %q", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateConfV1(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "build_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newData, changes, err := migrateConf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantChanges := []string{
+		"- enable_cgo_with_xgo: false",
+		"+ version: 3",
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("changes = %q, want %q", changes, wantChanges)
+	}
+
+	// the keys keep the order of the file, the version comes first.
+	oldKeys, _, _ := objectKeys(data)
+	wantKeys := []string{"version"}
+	for _, k := range oldKeys {
+		if k != "enable_cgo_with_xgo" {
+			wantKeys = append(wantKeys, k)
+		}
+	}
+	if keys, _, _ := objectKeys(newData); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys = %q, want %q", keys, wantKeys)
+	}
+
+	old, conf := map[string]any{}, map[string]any{}
+	json.Unmarshal(data, &old)
+	if err := json.Unmarshal(newData, &conf); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range old {
+		if k == "enable_cgo_with_xgo" {
+			continue
+		}
+		if !reflect.DeepEqual(conf[k], v) {
+			t.Errorf("%s = %v, want %v", k, conf[k], v)
+		}
+	}
+
+	// the migrated file loads into the same Builder as the unversioned one.
+	oldBuilder, newBuilder := New(), New()
+	if err := json.Unmarshal(data, oldBuilder); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(newData, newBuilder); err != nil {
+		t.Fatal(err)
+	}
+	oldJSON, _ := json.Marshal(oldBuilder)
+	newJSON, _ := json.Marshal(newBuilder)
+	if string(oldJSON) != string(newJSON) {
+		t.Errorf("migrated Builder = %s, want %s", newJSON, oldJSON)
+	}
+
+	again, changes, err := migrateConf(newData)
+	if err != nil || len(changes) > 0 || string(again) != string(newData) {
+		t.Errorf("migrating again: changes %q, err %v", changes, err)
+	}
+}
+
+func TestMarshalConfKeyOrder(t *testing.T) {
+	data := []byte(`{"work_dir": ".", "enable_cgo_with_xgo": true, "arch_os_list": ["linux/amd64"],
+	"profiles": [{"name": "b", "enable_zip": true, "enable_cgo_with_xgo": false}, {"name": "a"}]}`)
+	newData, _, err := migrateConf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+    "version": 3,
+    "work_dir": ".",
+    "arch_os_list": [
+        "linux/amd64"
+    ],
+    "profiles": [
+        {
+            "name": "b",
+            "enable_zip": true,
+            "cgo_mode": ""
+        },
+        {
+            "name": "a"
+        }
+    ],
+    "cgo_mode": "xgo"
+}`
+	if string(newData) != want {
+		t.Errorf("migrateConf() = %s, want %s", newData, want)
+	}
+}
+
+func TestMigrateConfFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "build_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildConf := filepath.Join(t.TempDir(), "build.json")
+	if err := os.WriteFile(buildConf, data, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := MigrateConfFile(buildConf); err != nil || len(changes) == 0 {
+		t.Fatalf("MigrateConfFile() = %q, %v, want changes", changes, err)
+	}
+	b := New()
+	if err := b.LoadConfFile(buildConf); err != nil {
+		t.Fatal(err)
+	}
+	if b.Version != ConfVersion || !b.EnableZip || len(b.ArchOSList) != 6 || b.BuildArgs[0] != "./cmd/gber" {
+		t.Errorf("loaded Builder lost fields: %+v", b)
+	}
+	if changes, err := MigrateConfFile(buildConf); err != nil || len(changes) > 0 {
+		t.Errorf("MigrateConfFile() again = %q, %v, want no changes", changes, err)
+	}
+}
+
+func TestMigrateConfTooNew(t *testing.T) {
+	if _, _, err := migrateConf([]byte(`{"version": 100}`)); !errors.Is(err, ErrConfTooNew) {
+		t.Errorf("migrateConf() of version 100 = %v, want %v", err, ErrConfTooNew)
+	}
+	if _, _, err := migrateConf([]byte(`{"version": "1"}`)); err == nil {
+		t.Error("migrateConf() of version \"1\" should fail")
+	}
+}
+
+func TestDiffConf(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{
+			name: "same",
+			old:  `{"a": 1, "b": [1, 2]}`,
+			new:  `{"b": [1, 2], "a": 1}`,
+		},
+		{
+			name: "added removed and changed",
+			old:  `{"a": 1, "b": "x"}`,
+			new:  `{"b": "y", "c": true}`,
+			want: []string{`- a: 1`, `- b: "x"`, `+ b: "y"`, `+ c: true`},
+		},
+		{
+			name: "profiles",
+			old:  `{"profiles": [{"name": "a"}, {"name": "b", "x": 1}]}`,
+			new:  `{"profiles": [{"name": "a", "y": 2}, {"name": "b"}]}`,
+			want: []string{`+ profiles[0].y: 2`, `- profiles[1].x: 1`},
+		},
+		{
+			name: "profiles added",
+			old:  `{"profiles": [{"name": "a"}]}`,
+			new:  `{"profiles": [{"name": "a"}, {"name": "b"}]}`,
+			want: []string{`- profiles: [{"name":"a"}]`, `+ profiles: [{"name":"a"},{"name":"b"}]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, new := map[string]any{}, map[string]any{}
+			json.Unmarshal([]byte(tt.old), &old)
+			json.Unmarshal([]byte(tt.new), &new)
+			if got := diffConf("", old, new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffConf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
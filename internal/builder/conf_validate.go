@@ -0,0 +1,291 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+const (
+	IssueError   string = "error"
+	IssueWarning string = "warning"
+)
+
+/*
+ConfIssue is a problem found in a build config file.
+*/
+type ConfIssue struct {
+	Level   string
+	Message string
+}
+
+func (i *ConfIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Level, i.Message)
+}
+
+// knownKeys are the json keys of Builder.
+func knownKeys() map[string]struct{} {
+	keys := map[string]struct{}{}
+	t := reflect.TypeOf(Builder{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = struct{}{}
+		}
+	}
+	return keys
+}
+
+func unknownKeys(conf map[string]any) (unknown []string) {
+	known := knownKeys()
+	for k := range conf {
+		if _, ok := known[k]; !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return
+}
+
+/*
+ValidateConfFile checks a build config file before building.
+
+The returned error is only for a file that cannot be read at all.
+*/
+func ValidateConfFile(buildConf string) (issues []*ConfIssue, err error) {
+	data, err := os.ReadFile(buildConf)
+	if err != nil {
+		return nil, err
+	}
+	addIssue := func(level, format string, v ...any) {
+		issues = append(issues, &ConfIssue{Level: level, Message: fmt.Sprintf(format, v...)})
+	}
+
+	raw := map[string]any{}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		addIssue(IssueError, "invalid json: %v", err)
+		return issues, nil
+	}
+	if version, err := confVersion(raw); err != nil {
+		addIssue(IssueError, "%v", err)
+	} else if version < ConfVersion {
+		addIssue(IssueWarning, "version %d is outdated, it will be migrated to version %d by the next build", version, ConfVersion)
+	}
+
+	// unknown keys are dropped silently when loading.
+	for _, k := range unknownKeys(raw) {
+		addIssue(IssueError, "unknown key %q", k)
+	}
+	// cgo_mode wins silently when building.
+	topMode, _ := raw["cgo_mode"].(string)
+	if mode := xgoConflict(raw, ""); mode != "" {
+		addIssue(IssueError, "enable_cgo_with_xgo is true but cgo_mode is %q, remove enable_cgo_with_xgo", mode)
+	}
+	if profiles, ok := raw["profiles"].([]any); ok {
+		for idx, p := range profiles {
+			profile, ok := p.(map[string]any)
+			if !ok {
+				addIssue(IssueError, "profiles[%d] is not an object", idx)
+				continue
+			}
+			for _, k := range unknownKeys(profile) {
+				addIssue(IssueError, "unknown key %q in profiles[%d]", k, idx)
+			}
+			if mode := xgoConflict(profile, topMode); mode != "" {
+				addIssue(IssueError, "enable_cgo_with_xgo is true but cgo_mode is %q in profiles[%d], remove enable_cgo_with_xgo", mode, idx)
+			}
+		}
+	}
+
+	b := New()
+	if err := b.LoadConfFile(buildConf); err != nil {
+		addIssue(IssueError, "%v", err)
+		return issues, nil
+	}
+	names, err := b.ProfileNames()
+	if err != nil {
+		addIssue(IssueError, "%v", err)
+		return issues, nil
+	}
+
+	supported := map[string]struct{}{}
+	for _, osArch := range utils.GetAllArchOS() {
+		supported[osArch] = struct{}{}
+	}
+	for _, name := range names {
+		pb, err := b.Profile(name)
+		if err != nil {
+			addIssue(IssueError, "%v", err)
+			continue
+		}
+		for _, issue := range pb.validate(supported) {
+			if len(b.Profiles) > 0 {
+				issue.Message = fmt.Sprintf("profile %q: %s", name, issue.Message)
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func (b *Builder) validate(supported map[string]struct{}) (issues []*ConfIssue) {
+	addIssue := func(level, format string, v ...any) {
+		issues = append(issues, &ConfIssue{Level: level, Message: fmt.Sprintf(format, v...)})
+	}
+
+	if len(b.ArchOSList) == 0 {
+		addIssue(IssueError, "arch_os_list is empty")
+	}
+	for _, osArch := range b.ArchOSList {
+		osInfo, archInfo, _, err := ParseTarget(osArch)
+		if err != nil {
+			addIssue(IssueError, "%v", err)
+			continue
+		}
+		if _, ok := supported[osInfo+"/"+archInfo]; !ok && len(supported) > 0 {
+			addIssue(IssueError, "%q is not supported by `go tool dist list`", osArch)
+		}
+	}
+
+	overrideKeys := make([]string, 0, len(b.Overrides))
+	for key := range b.Overrides {
+		overrideKeys = append(overrideKeys, key)
+	}
+	sort.Strings(overrideKeys)
+	for _, key := range overrideKeys {
+		if _, err := path.Match(key, ""); err != nil {
+			addIssue(IssueError, "invalid pattern %q in overrides: %v", key, err)
+			continue
+		}
+		matched := false
+		for _, osArch := range b.ArchOSList {
+			if matchTarget(key, osArch) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			addIssue(IssueWarning, "overrides %q matches no target in arch_os_list", key)
+		}
+	}
+
+	checkEnv := func(field string, env map[string]string) {
+		for k := range env {
+			if k == "" || strings.ContainsAny(k, "= ") {
+				addIssue(IssueError, "invalid env name %q in %s", k, field)
+			}
+		}
+	}
+	checkEnv("env", b.Env)
+	for _, key := range overrideKeys {
+		if ov := b.Overrides[key]; ov != nil {
+			checkEnv(fmt.Sprintf("overrides %q", key), ov.Env)
+		}
+	}
+
+	if !IsValidCGoMode(b.CGoMode) {
+		addIssue(IssueError, "cgo_mode %q is not one of native, zig and xgo", b.CGoMode)
+	} else if b.CGoMode == CGoModeNative || b.CGoMode == CGoModeZig {
+		triples := gccTriples
+		if b.CGoMode == CGoModeZig {
+			triples = zigTriples
+		}
+		for _, osArch := range b.ArchOSList {
+			osInfo, archInfo, _, err := ParseTarget(osArch)
+			if err != nil {
+				continue
+			}
+			if _, ok := triples[osInfo+"/"+archInfo]; !ok && !b.hasCC(osArch) {
+				addIssue(IssueWarning, "cgo_mode %s has no C compiler for %s, set CC in env", b.CGoMode, osArch)
+			}
+		}
+	}
+
+	for _, h := range []struct {
+		kind     string
+		commands []string
+	}{
+		{"before", b.Hooks.Before},
+		{"before_each", b.Hooks.BeforeEach},
+		{"after_each", b.Hooks.AfterEach},
+		{"after", b.Hooks.After},
+	} {
+		for _, command := range h.commands {
+			if _, err := utils.SplitShellWords(command); err != nil {
+				addIssue(IssueError, "invalid %s hook %q: %v", h.kind, command, err)
+			}
+		}
+	}
+
+	for _, step := range b.Pipeline {
+		if step == nil {
+			continue
+		}
+		if _, ok := lookupPostProcessor(step.Step); !ok {
+			addIssue(IssueError, "unknown step %q in pipeline, available: %s", step.Step, strings.Join(PostProcessorNames(), ", "))
+		}
+		for _, key := range step.Targets {
+			if _, err := path.Match(key, ""); err != nil {
+				addIssue(IssueError, "invalid pattern %q in pipeline step %s: %v", key, step.Step, err)
+			}
+		}
+	}
+
+	if !IsValidArchiveFormat(b.ArchiveFormat) {
+		addIssue(IssueError, "archive_format %q is not one of zip, tar.gz, tar.xz and auto", b.ArchiveFormat)
+	}
+
+	sample := b.nameData("linux", "amd64", "app")
+	for _, t := range []struct{ key, tmpl, def string }{
+		{"binary", b.NameTemplates.Binary, DefaultBinaryTemplate},
+		{"dir", b.NameTemplates.Dir, DefaultDirTemplate},
+		{"archive", b.NameTemplates.Archive, DefaultArchiveTemplate},
+	} {
+		if name, err := renderTemplate(t.tmpl, t.def, sample); err != nil {
+			addIssue(IssueError, "invalid name_templates.%s: %v", t.key, err)
+		} else if name == "" {
+			addIssue(IssueError, "name_templates.%s renders an empty name", t.key)
+		}
+	}
+
+	projectDir := b.ProjectDir()
+	for _, f := range b.ArchiveFiles {
+		matches, err := filepath.Glob(filepath.Join(projectDir, f.Src))
+		if err != nil {
+			addIssue(IssueError, "invalid pattern %q in archive_files: %v", f.Src, err)
+		} else if len(matches) == 0 {
+			addIssue(IssueError, "no file matches %q in archive_files", f.Src)
+		}
+	}
+
+	if b.EnableOsslsigncode {
+		if b.OsslPfxFilePath == "" {
+			addIssue(IssueError, "ossl_pfx_file_path is empty")
+		} else if ok, _ := gutils.PathIsExist(b.OsslPfxFilePath); !ok {
+			addIssue(IssueError, "pfx file %q is not found", b.OsslPfxFilePath)
+		}
+	}
+
+	if b.OsslPfxPassword != "" && !IsSecretRef(b.OsslPfxPassword) {
+		addIssue(IssueWarning, "ossl_pfx_password is a literal secret, use a reference like %q instead", DefaultPfxPasswordRef)
+	} else if b.EnableOsslsigncode && strings.HasPrefix(b.OsslPfxPassword, SecretEnvPrefix) {
+		if _, ok := os.LookupEnv(strings.TrimPrefix(b.OsslPfxPassword, SecretEnvPrefix)); !ok {
+			addIssue(IssueWarning, "environment variable for ossl_pfx_password is not set: %s", b.OsslPfxPassword)
+		}
+	}
+
+	if b.WorkDir != "" {
+		if ok, _ := gutils.PathIsExist(b.WorkDir); !ok {
+			addIssue(IssueError, "work_dir %q is not found", b.WorkDir)
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+expandEnv returns the env vars like "KEY=value", sorted by key.
+
+Templates like {{.Version}} and command substitutions are expanded in the values,
+both "#(...)" and "$(...)" are accepted.
+*/
+func (b *Builder) expandEnv(env map[string]string, data *NameData) ([]string, error) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := hashSubstitutions(env[k])
+		if strings.Contains(value, "{{") {
+			rendered, err := renderTemplate(value, "", data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template in env %s: %w", k, err)
+			}
+			value = rendered
+		}
+		value, err := b.expandSubstitutions(value)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		result = append(result, fmt.Sprintf("%s=%s", k, value))
+	}
+	return result, nil
+}
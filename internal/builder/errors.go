@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrGoNotInstalled    = errors.New("go compiler is not installed")
+	ErrProjectNotFound   = errors.New("not found go project")
+	ErrNoTarget          = errors.New("no target Os/Arch chosen")
+	ErrProfileNotFound   = errors.New("profile is not found")
+	ErrProfileNameNeeded = errors.New("profile has no name")
+	ErrConfTooNew        = errors.New("build config file is created by a newer gber")
+	ErrConfExists        = errors.New("build config file already exists")
+	ErrConfNotFound      = errors.New("build config file is not found, create it with `gber init`")
+	ErrNotInteractive    = errors.New("stdin is not a terminal, cannot ask for build options; create build.json with `gber init --yes`")
+	ErrToolNotInstalled  = errors.New("tool is not installed")
+	ErrXgoImageNotFound  = errors.New("xgo docker image is not found")
+	ErrPfxFileNotFound   = errors.New("pfx file is not found")
+	ErrPfxPasswordNeeded = errors.New("pfx password is not set")
+	ErrArchiveFormat     = errors.New("unsupported archive format")
+	ErrSkipped           = errors.New("skipped after a previous failure")
+	ErrCGoMode           = errors.New("unsupported cgo mode")
+	ErrUnknownStep       = errors.New("unknown pipeline step")
+	ErrInvalidTarget     = errors.New("invalid target")
+	ErrSourceDateEpoch   = errors.New("reproducible build needs a git commit or SOURCE_DATE_EPOCH")
+	ErrNotReproducible   = errors.New("artifacts are not reproducible")
+)
+
+/*
+Steps of the build pipeline.
+*/
+const (
+	StepConfig       string = "config"
+	StepPrepare      string = "prepare"
+	StepCompile      string = "compile"
+	StepUPX          string = "upx"
+	StepOsslsigncode string = "osslsigncode"
+	StepArchive      string = "archive"
+	StepRelease      string = "release"
+	StepHook         string = "hook"
+)
+
+/*
+StepError is returned when a step of the build pipeline fails.
+*/
+type StepError struct {
+	Target string
+	Step   string
+	Err    error
+}
+
+func (e *StepError) Error() string {
+	if e.Target == "" {
+		return fmt.Sprintf("%s: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("%s [%s]: %v", e.Step, e.Target, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+func newStepError(target, step string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StepError{Target: target, Step: step, Err: err}
+}
@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+Event types, for machine-readable output like `gber build --output json`.
+*/
+const (
+	EventTargetStarted  string = "target_started"
+	EventCommand        string = "command"
+	EventStepFinished   string = "step_finished"
+	EventArtifact       string = "artifact"
+	EventError          string = "error"
+	EventTargetFinished string = "target_finished"
+	EventSummary        string = "summary"
+)
+
+/*
+Kinds of artifacts in the artifact events.
+*/
+const (
+	ArtifactBinary    string = "binary"
+	ArtifactArchive   string = "archive"
+	ArtifactChecksums string = "checksums"
+	ArtifactManifest  string = "manifest"
+)
+
+/*
+Event is a step of the build, fields not related to the type are empty.
+*/
+type Event struct {
+	Type       string             `json:"type"`
+	Time       time.Time          `json:"time"`
+	Profile    string             `json:"profile,omitempty"`
+	Target     string             `json:"target,omitempty"`
+	Step       string             `json:"step,omitempty"`
+	Command    []string           `json:"command,omitempty"`
+	Kind       string             `json:"kind,omitempty"` // of the artifact.
+	Path       string             `json:"path,omitempty"`
+	Status     string             `json:"status,omitempty"`
+	DurationMs int64              `json:"duration_ms,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Artifacts  []*SummaryArtifact `json:"artifacts,omitempty"` // of the summary.
+	Checksums  []string           `json:"checksums,omitempty"` // of the summary.
+	Manifest   string             `json:"manifest,omitempty"`  // of the summary.
+}
+
+/*
+SummaryArtifact is a target in the summary event.
+*/
+type SummaryArtifact struct {
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Binary     string `json:"binary,omitempty"`
+	Archive    string `json:"archive,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+/*
+EventHandler receives the events of a build, it is called from the goroutines of parallel targets.
+*/
+type EventHandler func(e *Event)
+
+/*
+NewJSONEventHandler writes the events to w as newline-delimited JSON.
+*/
+func NewJSONEventHandler(w io.Writer) EventHandler {
+	lock := &sync.Mutex{}
+	encoder := json.NewEncoder(w)
+	return func(e *Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		encoder.Encode(e)
+	}
+}
+
+/*
+out is where the messages for humans go, the events have their own writer.
+*/
+func (b *Builder) out() io.Writer {
+	if b.Out == nil {
+		return os.Stdout
+	}
+	return b.Out
+}
+
+func (b *Builder) emit(e *Event) {
+	if b.Events == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Profile = b.Name
+	b.Events(e)
+}
+
+func (b *Builder) emitError(target string, err error) {
+	e := &Event{Type: EventError, Target: target, Error: err.Error()}
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		e.Step = stepErr.Step
+	}
+	b.emit(e)
+}
+
+func (b *Builder) emitSummary(report *Report, err error) {
+	e := &Event{
+		Type:      EventSummary,
+		Status:    StatusOK,
+		Artifacts: []*SummaryArtifact{},
+		Checksums: report.Checksums,
+		Manifest:  report.Manifest,
+	}
+	for _, res := range report.Results {
+		if res == nil {
+			continue
+		}
+		a := &SummaryArtifact{
+			Target:     res.Target,
+			Status:     res.Status(),
+			Binary:     res.Binary,
+			Archive:    res.Archive,
+			DurationMs: res.Duration.Milliseconds(),
+		}
+		if res.Err != nil {
+			a.Error = res.Err.Error()
+		}
+		e.Artifacts = append(e.Artifacts, a)
+	}
+	if err == nil {
+		err = report.Err()
+	}
+	if err != nil {
+		e.Status = StatusFailed
+		e.Error = err.Error()
+	}
+	b.emit(e)
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeTool puts a shell script as the tool on PATH.
+func fakeTool(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPostProcessorCommandEvents(t *testing.T) {
+	fakeTool(t, "upx", `[ "$1" = "--version" ] && exit 0; cp "$4" "$3"`+"\n")
+	fakeTool(t, "osslsigncode", `[ "$1" = "--version" ] && exit 0
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-in) in="$2" ;;
+	-out) out="$2" ;;
+	esac
+	shift
+done
+cp "$in" "$out"
+`)
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "app.exe"), []byte("bin"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	pfx := filepath.Join(binDir, "cert.pfx")
+	if err := os.WriteFile(pfx, []byte("pfx"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	events, out := []*Event{}, &bytes.Buffer{}
+	b := New()
+	b.Out = out
+	b.EnableUPX = true
+	b.EnableOsslsigncode = true
+	b.OsslPfxFilePath = pfx
+	b.OsslPfxPassword = "secret-password"
+	b.Events = func(e *Event) { events = append(events, e) }
+
+	a := &StepArtifact{Target: "windows/amd64", Os: "windows", Arch: "amd64", BinDir: binDir, BinName: "app.exe"}
+	if err := b.packWithUPX(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.signWithOsslsigncode(a); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "Packing with UPX") || !strings.Contains(out.String(), "Signing with osslsigncode") {
+		t.Errorf("messages are not written to Out: %q", out.String())
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for idx, step := range []string{StepUPX, StepOsslsigncode} {
+		e := events[idx]
+		if e.Type != EventCommand || e.Step != step || e.Target != a.Target || len(e.Command) == 0 {
+			t.Errorf("event %d = %+v, want the command of %s", idx, e, step)
+		}
+		if strings.Contains(strings.Join(e.Command, " "), b.OsslPfxPassword) {
+			t.Errorf("event %d has the password: %q", idx, e.Command)
+		}
+	}
+}
+
+func TestPipelineSkippedSteps(t *testing.T) {
+	RegisterPostProcessor("test_noop", PostProcessorFunc(func(ctx *PostContext, a *StepArtifact) (*StepArtifact, error) {
+		return a, nil
+	}))
+
+	events := []*Event{}
+	b := New()
+	b.Out = &bytes.Buffer{}
+	b.EnableOsslsigncode = true // linux binaries are not signed.
+	b.Pipeline = append([]*PipelineStep{{Step: "test_noop"}}, DefaultPipeline...)
+	b.Events = func(e *Event) { events = append(events, e) }
+
+	a := &StepArtifact{Target: "linux/amd64", Os: "linux", Arch: "amd64", BinDir: t.TempDir(), BinName: "app"}
+	if _, err := b.runPipeline(a, nil, b.Out); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventStepFinished || events[0].Step != "test_noop" {
+		t.Errorf("events = %+v, want step_finished of test_noop only", events)
+	}
+}
@@ -0,0 +1,130 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+)
+
+/*
+Hooks are commands run around the build, like:
+
+	"hooks": {
+	    "before": ["go generate ./...", "go test ./..."],
+	    "after_each": ["./scripts/notarize.sh"],
+	    "after": ["sh -c \"cp build/*.zip /mnt/mirror/\""]
+	}
+
+The commands are split like in a shell, without a shell. Templates like {{.Os}} are expanded.
+The target and the artifacts are in the GBER_* env vars. A failing hook aborts the run.
+*/
+type Hooks struct {
+	Before     []string `json:"before,omitempty"`      // before all targets.
+	BeforeEach []string `json:"before_each,omitempty"` // before compiling a target.
+	AfterEach  []string `json:"after_each,omitempty"`  // after a target is archived.
+	After      []string `json:"after,omitempty"`       // after the checksums and the manifest are written.
+}
+
+/*
+hookEnv returns the env vars for the hooks, result is nil for before and after.
+*/
+func (b *Builder) hookEnv(result *TargetResult, report *Report) []string {
+	env := append(
+		os.Environ(),
+		"GBER_PROFILE="+b.Name,
+		"GBER_PROJECT_DIR="+b.ProjectDir(),
+		"GBER_BUILD_DIR="+filepath.Join(b.ProjectDir(), "build"),
+	)
+	if b.vars != nil {
+		env = append(
+			env,
+			"GBER_VERSION="+b.vars.Version,
+			"GBER_GIT_TAG="+b.vars.GitTag,
+			"GBER_COMMIT="+b.vars.Commit,
+		)
+	}
+	if result != nil {
+		env = append(
+			env,
+			"GBER_TARGET="+result.Target,
+			"GBER_OS="+result.Os,
+			"GBER_ARCH="+result.Arch,
+			"GBER_VARIANT="+result.Variant,
+			"GBER_BINARY="+result.Binary,
+			"GBER_ARCHIVE="+result.Archive,
+		)
+	}
+	if report != nil {
+		artifacts := []string{}
+		for _, res := range report.Results {
+			if res == nil || res.Err != nil {
+				continue
+			}
+			if res.Binary != "" {
+				artifacts = append(artifacts, res.Binary)
+			}
+			if res.Archive != "" {
+				artifacts = append(artifacts, res.Archive)
+			}
+		}
+		env = append(
+			env,
+			"GBER_ARTIFACTS="+strings.Join(artifacts, string(os.PathListSeparator)),
+			"GBER_CHECKSUMS="+strings.Join(report.Checksums, string(os.PathListSeparator)),
+			"GBER_MANIFEST="+report.Manifest,
+		)
+	}
+	return env
+}
+
+/*
+hookArgs renders the templates in a hook command, and splits it to args.
+*/
+func hookArgs(kind, command string, data *NameData) ([]string, error) {
+	if strings.Contains(command, "{{") {
+		rendered, err := renderTemplate(command, "", data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in %s hook %q: %w", kind, command, err)
+		}
+		command = rendered
+	}
+	args, err := utils.SplitShellWords(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s hook %q: %w", kind, command, err)
+	}
+	return args, nil
+}
+
+/*
+runHooks runs the commands one by one, and stops at the first failure.
+*/
+func (b *Builder) runHooks(kind, target string, commands []string, data *NameData, env []string, out io.Writer) error {
+	for _, command := range commands {
+		args, err := hookArgs(kind, command, data)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		utils.PrintInfo(b.out(), "Running %s hook: %s", kind, command)
+		b.emit(&Event{Type: EventCommand, Target: target, Step: StepHook, Command: args})
+		if err = utils.ExecuteCommand(b.WorkDir, env, out, out, args...); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", kind, command, err)
+		}
+	}
+	return nil
+}
+
+/*
+isHookError reports whether err is from a hook, hook failures abort the run.
+*/
+func isHookError(err error) bool {
+	var stepErr *StepError
+	return errors.As(err, &stepErr) && stepErr.Step == StepHook
+}
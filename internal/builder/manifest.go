@@ -0,0 +1,165 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+const (
+	ChecksumsFileName       string = "checksums.txt"
+	ChecksumsSHA512FileName string = "checksums.sha512.txt"
+	ManifestFileName        string = "manifest.json"
+)
+
+/*
+Manifest lists the artifacts of a build for downstream installers.
+Paths are relative to the build dir.
+*/
+type Manifest struct {
+	Profile   string              `json:"profile"`
+	GoVersion string              `json:"go_version"`
+	BuildArgs []string            `json:"build_args"`
+	Variables *BuildVars          `json:"variables"`
+	Artifacts []*ManifestArtifact `json:"artifacts"`
+}
+
+type ManifestArtifact struct {
+	Target        string   `json:"target"`
+	Os            string   `json:"os"`
+	Arch          string   `json:"arch"`
+	Variant       string   `json:"variant,omitempty"`
+	Binary        string   `json:"binary"`
+	BinarySize    int64    `json:"binary_size"`
+	BinarySHA256  string   `json:"binary_sha256"`
+	Archive       string   `json:"archive,omitempty"`
+	ArchiveSize   int64    `json:"archive_size,omitempty"`
+	ArchiveSHA256 string   `json:"archive_sha256,omitempty"`
+	BuildArgs     []string `json:"build_args"`
+}
+
+func GoVersion() string {
+	buf, err := gutils.ExecuteSysCommand(true, "", "go", "env", "GOVERSION")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// releaseFileName prefixes the name with the profile, so that profiles do not overwrite each other.
+func (b *Builder) releaseFileName(name string) string {
+	if b.Name == "" || b.Name == DefaultProfile {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", b.Name, name)
+}
+
+/*
+writeReleaseFiles writes the checksums and the manifest of the successfully built targets.
+*/
+func (b *Builder) writeReleaseFiles(report *Report) error {
+	buildDir := filepath.Join(b.ProjectDir(), "build")
+	manifest := &Manifest{
+		Profile:   b.Name,
+		GoVersion: b.vars.GoVersion,
+		BuildArgs: b.BuildArgs,
+		Variables: b.vars,
+		Artifacts: []*ManifestArtifact{},
+	}
+	sha256Sums := map[string]string{}
+	sha512Sums := map[string]string{}
+
+	// checksum computes the sums of a file, and returns its path relative to the build dir.
+	checksum := func(fPath string) (relPath string, size int64, sum string, err error) {
+		info, err := os.Stat(fPath)
+		if err != nil {
+			return
+		}
+		if relPath, err = filepath.Rel(buildDir, fPath); err != nil {
+			return
+		}
+		relPath = filepath.ToSlash(relPath)
+		if sum = gutils.ComputeSum(fPath, "sha256"); sum == "" {
+			return "", 0, "", fmt.Errorf("failed to compute sha256 of %s", fPath)
+		}
+		sha256Sums[relPath] = sum
+		if b.EnableSHA512 {
+			if sha512Sums[relPath] = gutils.ComputeSum(fPath, "sha512"); sha512Sums[relPath] == "" {
+				return "", 0, "", fmt.Errorf("failed to compute sha512 of %s", fPath)
+			}
+		}
+		return relPath, info.Size(), sum, nil
+	}
+
+	for _, res := range report.Results {
+		if res == nil || res.Err != nil || res.Binary == "" {
+			continue
+		}
+		a := &ManifestArtifact{
+			Target:    res.Target,
+			Os:        res.Os,
+			Arch:      res.Arch,
+			Variant:   res.Variant,
+			BuildArgs: res.Args,
+		}
+		var err error
+		if a.Binary, a.BinarySize, a.BinarySHA256, err = checksum(res.Binary); err != nil {
+			return err
+		}
+		if res.Archive != "" {
+			if a.Archive, a.ArchiveSize, a.ArchiveSHA256, err = checksum(res.Archive); err != nil {
+				return err
+			}
+		}
+		manifest.Artifacts = append(manifest.Artifacts, a)
+	}
+	if len(manifest.Artifacts) == 0 {
+		return nil
+	}
+
+	checksumsPath := filepath.Join(buildDir, b.releaseFileName(ChecksumsFileName))
+	if err := writeChecksums(checksumsPath, sha256Sums); err != nil {
+		return err
+	}
+	report.Checksums = append(report.Checksums, checksumsPath)
+	if b.EnableSHA512 {
+		checksumsPath = filepath.Join(buildDir, b.releaseFileName(ChecksumsSHA512FileName))
+		if err := writeChecksums(checksumsPath, sha512Sums); err != nil {
+			return err
+		}
+		report.Checksums = append(report.Checksums, checksumsPath)
+	}
+
+	manifestPath := filepath.Join(buildDir, b.releaseFileName(ManifestFileName))
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(manifestPath, data, os.ModePerm); err != nil {
+		return err
+	}
+	report.Manifest = manifestPath
+	return nil
+}
+
+/*
+writeChecksums writes sums in the format of sha256sum, so that "sha256sum -c" works.
+*/
+func writeChecksums(fPath string, sums map[string]string) error {
+	paths := []string{}
+	for p := range sums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	content := strings.Builder{}
+	for _, p := range paths {
+		content.WriteString(fmt.Sprintf("%s  %s\n", sums[p], p))
+	}
+	return os.WriteFile(fPath, []byte(content.String()), os.ModePerm)
+}
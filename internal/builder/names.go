@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+/*
+Default name templates, the same as the names before templates were supported.
+The variant of a target like linux/arm/v7 is appended as "-v7".
+*/
+const (
+	DefaultBinaryTemplate  string = "{{.Name}}"
+	DefaultDirTemplate     string = "{{.Os}}-{{.Arch}}{{with .Variant}}-{{.}}{{end}}"
+	DefaultArchiveTemplate string = "{{.Name}}_{{.Os}}-{{.Arch}}{{with .Variant}}-{{.}}{{end}}"
+)
+
+/*
+NameTemplates are go templates for the names of artifacts, like:
+
+	{"archive": "{{.Name}}_{{.Version}}_{{.Os}}_{{.Arch}}"}
+
+".exe" is appended to windows binaries automatically, and the archive format to archives.
+*/
+type NameTemplates struct {
+	Binary  string `json:"binary,omitempty"`
+	Dir     string `json:"dir,omitempty"` // the dir in build/ for the binary.
+	Archive string `json:"archive,omitempty"`
+}
+
+/*
+NameData is available in the name templates, in BuildArgs, env and hooks.
+
+The variables are all the BuildVars, and {{.Name}}, {{.Tag}}, {{.Os}}, {{.Arch}}, {{.Arm}}, {{.Variant}},
+{{.GoOs}} and {{.GoArch}}. Os and Arch are mapped by os_aliases and arch_aliases, like amd64 to x86_64.
+*/
+type NameData struct {
+	*BuildVars
+	Name    string // name of the binary, from -o or the main package.
+	Tag     string // same as GitTag.
+	Os      string
+	Arch    string
+	Arm     string // GOARM of the target from the variant or env, like 7.
+	Variant string // sub-architecture of the target, like v7 for linux/arm/v7 or v3 for linux/amd64/v3.
+	GoOs    string // Os without alias.
+	GoArch  string // Arch without alias.
+}
+
+func (b *Builder) nameData(osInfo, archInfo, name string) *NameData {
+	vars := b.vars
+	if vars == nil {
+		vars = &BuildVars{Version: "dev"}
+	}
+	data := &NameData{
+		BuildVars: vars,
+		Name:      strings.TrimSuffix(name, winSuffix),
+		Tag:       vars.GitTag,
+		Os:        osInfo,
+		Arch:      archInfo,
+		GoOs:      osInfo,
+		GoArch:    archInfo,
+		Variant:   b.variant,
+	}
+	if alias, ok := b.OsAliases[osInfo]; ok {
+		data.Os = alias
+	}
+	if alias, ok := b.ArchAliases[archInfo]; ok {
+		data.Arch = alias
+	}
+	if archInfo == "arm" {
+		data.Arm = b.goarm()
+	}
+	return data
+}
+
+/*
+goarm returns GOARM in the env of the compiler, the env of build.json and the override
+win over the variant like buildEnv. Templates and substitutions in the value are not expanded.
+*/
+func (b *Builder) goarm() string {
+	arm := strings.TrimPrefix(b.variant, "v")
+	if v, ok := b.Env["GOARM"]; ok {
+		arm = v
+	}
+	if b.override != nil {
+		if v, ok := b.override.Env["GOARM"]; ok {
+			arm = v
+		}
+	}
+	return arm
+}
+
+func renderTemplate(tmpl, defaultTmpl string, data *NameData) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultTmpl
+	}
+	t, err := template.New("gber").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err = t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,35 @@
+package builder
+
+import "testing"
+
+func TestNameDataArm(t *testing.T) {
+	t.Setenv("GOARM", "5") // the env of gber is not the env of the target.
+	tests := []struct {
+		name     string
+		arch     string
+		variant  string
+		env      map[string]string
+		override map[string]string
+		want     string
+	}{
+		{name: "no variant", arch: "arm"},
+		{name: "variant", arch: "arm", variant: "v7", want: "7"},
+		{name: "env", arch: "arm", env: map[string]string{"GOARM": "6"}, want: "6"},
+		{name: "env wins over variant", arch: "arm", variant: "v7", env: map[string]string{"GOARM": "6"}, want: "6"},
+		{name: "override wins", arch: "arm", env: map[string]string{"GOARM": "6"}, override: map[string]string{"GOARM": "7"}, want: "7"},
+		{name: "not arm", arch: "amd64", variant: "v3", env: map[string]string{"GOARM": "6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New()
+			b.Env = tt.env
+			b.variant = tt.variant
+			if tt.override != nil {
+				b.override = &TargetOverride{Env: tt.override}
+			}
+			if got := b.nameData("linux", tt.arch, "app").Arm; got != tt.want {
+				t.Errorf("Arm = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 
-	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+	"github.com/gvcgo/gobuilder/internal/utils"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
 
@@ -19,57 +19,76 @@ func IsOsslsigncodeInstalled() (ok bool) {
 	return err == nil
 }
 
-func (b *Builder) SignWithOsslsigncode(osInfo, archInfo, binDir, binName string) {
-	if !b.EnableOsslsigncode {
-		return
-	}
-
-	// Only sign windows binaries.
-	if osInfo != gutils.Windows {
-		gprint.PrintWarning("only sign windows binaries.")
-		return
-	}
-
-	if !IsOsslsigncodeInstalled() {
-		return
-	}
-	if ok, _ := gutils.PathIsExist(b.OsslPfxFilePath); !ok || b.OsslPfxPassword == "" {
-		return
-	}
-
-	gprint.PrintInfo("Signing with osslsigncode...")
-	binPath := filepath.Join(binDir, binName)
-	signedBinPath := filepath.Join(binDir, fmt.Sprintf("signed_%s", binName))
+/*
+osslsigncodeArgs returns the osslsigncode command, the signed binary replaces the binary afterwards.
 
-	/*
-		osslsigncode sign -addUnauthenticatedBlob -pkcs12
-		/home/moqsien/golang/src/gvcgo/version-manager/scripts/vmr.pfx
-		-pass Vmr2024 -n "GVC" -i https://github.com/gvcgo/ -in vmr.exe -out vmr_signed.exe
-	*/
-	_, err := gutils.ExecuteSysCommand(
-		true,
-		binDir,
+	osslsigncode sign -addUnauthenticatedBlob -pkcs12
+	/home/moqsien/golang/src/gvcgo/version-manager/scripts/vmr.pfx
+	-pass Vmr2024 -n "GVC" -i https://github.com/gvcgo/ -in vmr.exe -out vmr_signed.exe
+*/
+func (b *Builder) osslsigncodeArgs(binDir, binName, password string) (args []string, signedBinPath string) {
+	signedBinPath = filepath.Join(binDir, fmt.Sprintf("signed_%s", binName))
+	args = []string{
 		"osslsigncode",
 		"sign",
 		"-addUnauthenticatedBlob",
 		"-pkcs12",
 		b.OsslPfxFilePath,
 		"-pass",
-		b.OsslPfxPassword,
+		password,
 		"-n",
 		b.OsslPfxCompany,
 		"-i",
 		b.OsslPfxWebsite,
 		"-in",
-		binPath,
+		filepath.Join(binDir, binName),
 		"-out",
 		signedBinPath,
-	)
+	}
+	return args, signedBinPath
+}
+
+func (b *Builder) SignWithOsslsigncode(osInfo, archInfo, binDir, binName string) error {
+	return b.signWithOsslsigncode(&StepArtifact{Target: osInfo + "/" + archInfo, Os: osInfo, Arch: archInfo, BinDir: binDir, BinName: binName})
+}
+
+func (b *Builder) signWithOsslsigncode(a *StepArtifact) error {
+	osInfo, binDir, binName := a.Os, a.BinDir, a.BinName
+	if !b.EnableOsslsigncode {
+		return nil
+	}
+
+	// Only sign windows binaries.
+	if osInfo != gutils.Windows {
+		utils.PrintWarning(b.out(), "only sign windows binaries.")
+		return nil
+	}
+
+	if !IsOsslsigncodeInstalled() {
+		return fmt.Errorf("osslsigncode: %w", ErrToolNotInstalled)
+	}
+	if ok, _ := gutils.PathIsExist(b.OsslPfxFilePath); !ok {
+		return fmt.Errorf("%w: %s", ErrPfxFileNotFound, b.OsslPfxFilePath)
+	}
+	password, err := ResolveSecret(b.OsslPfxPassword, b.WorkDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve pfx password: %w", err)
+	}
+	if password == "" {
+		return ErrPfxPasswordNeeded
+	}
+
+	utils.PrintInfo(b.out(), "Signing with osslsigncode for %s...", a.Target)
+	binPath := filepath.Join(binDir, binName)
+	args, signedBinPath := b.osslsigncodeArgs(binDir, binName, password)
+	// the password is not written to the events.
+	masked, _ := b.osslsigncodeArgs(binDir, binName, "******")
+	b.emit(&Event{Type: EventCommand, Target: a.Target, Step: StepOsslsigncode, Command: masked})
+	_, err = gutils.ExecuteSysCommand(true, binDir, args...)
 	if err != nil {
-		gprint.PrintError("Failed to sign binary: %+v", err)
 		os.RemoveAll(signedBinPath)
-		return
+		return fmt.Errorf("failed to sign binary: %w", err)
 	}
 	os.RemoveAll(binPath)
-	os.Rename(signedBinPath, binPath)
+	return os.Rename(signedBinPath, binPath)
 }
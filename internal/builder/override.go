@@ -0,0 +1,209 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+/*
+TargetOverride changes the build of the targets matching its key in build.json,
+the key is an Os/Arch or a glob pattern like "windows/*":
+
+	"overrides": {
+	    "linux/*": {"tags": ["netgo"]},
+	    "windows/*": {"ldflags": "-H windowsgui", "enable_upx": false},
+	    "linux/amd64": {"env": {"GOAMD64": "v3"}}
+	}
+
+Globs are applied before exact keys, so an exact key wins.
+*/
+type TargetOverride struct {
+	BuildArgs          []string          `json:"build_args,omitempty"` // replaces the build_args.
+	Args               []string          `json:"args,omitempty"`       // added before the package.
+	Ldflags            string            `json:"ldflags,omitempty"`    // added to -ldflags.
+	Tags               []string          `json:"tags,omitempty"`       // added to -tags.
+	Env                map[string]string `json:"env,omitempty"`
+	EnableZip          *bool             `json:"enable_zip,omitempty"`
+	EnableGarble       *bool             `json:"enable_garble,omitempty"`
+	EnableUPX          *bool             `json:"enable_upx,omitempty"`
+	EnableOsslsigncode *bool             `json:"enable_osslsigncode,omitempty"`
+}
+
+func isGlob(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+/*
+matchTarget reports whether key matches the target, keys of Os/Arch also match the variants like linux/arm/v7.
+*/
+func matchTarget(key, target string) bool {
+	if ok, _ := path.Match(key, target); ok {
+		return true
+	}
+	if osInfo, archInfo, variant, err := ParseTarget(target); err == nil && variant != "" {
+		ok, _ := path.Match(key, osInfo+"/"+archInfo)
+		return ok
+	}
+	return false
+}
+
+/*
+overrideKeys returns the keys matching the target, in the order they are applied.
+*/
+func (b *Builder) overrideKeys(target string) (keys []string) {
+	for key := range b.Overrides {
+		if matchTarget(key, target) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if isGlob(keys[i]) != isGlob(keys[j]) {
+			return isGlob(keys[i])
+		}
+		return keys[i] < keys[j]
+	})
+	return
+}
+
+/*
+targetOverride merges the overrides matching the target, nil if there is none.
+*/
+func (b *Builder) targetOverride(target string) *TargetOverride {
+	keys := b.overrideKeys(target)
+	if len(keys) == 0 {
+		return nil
+	}
+	merged := &TargetOverride{Env: map[string]string{}}
+	for _, key := range keys {
+		ov := b.Overrides[key]
+		if ov == nil {
+			continue
+		}
+		if ov.BuildArgs != nil {
+			merged.BuildArgs = ov.BuildArgs
+		}
+		merged.Args = append(merged.Args, ov.Args...)
+		if ov.Ldflags != "" {
+			merged.Ldflags = strings.TrimSpace(merged.Ldflags + " " + ov.Ldflags)
+		}
+		merged.Tags = append(merged.Tags, ov.Tags...)
+		for k, v := range ov.Env {
+			merged.Env[k] = v
+		}
+		if ov.EnableZip != nil {
+			merged.EnableZip = ov.EnableZip
+		}
+		if ov.EnableGarble != nil {
+			merged.EnableGarble = ov.EnableGarble
+		}
+		if ov.EnableUPX != nil {
+			merged.EnableUPX = ov.EnableUPX
+		}
+		if ov.EnableOsslsigncode != nil {
+			merged.EnableOsslsigncode = ov.EnableOsslsigncode
+		}
+	}
+	return merged
+}
+
+/*
+forTarget returns a copy of the builder for the target, with the variant and the overrides applied.
+*/
+func (b *Builder) forTarget(result *TargetResult) *Builder {
+	tb := *b
+	tb.variant = result.Variant
+	ov := b.targetOverride(result.Target)
+	if ov == nil {
+		return &tb
+	}
+	tb.override = ov
+	if ov.BuildArgs != nil {
+		tb.BuildArgs = ov.BuildArgs
+	}
+	if ov.EnableZip != nil {
+		tb.EnableZip = *ov.EnableZip
+	}
+	if ov.EnableGarble != nil {
+		tb.EnableGarble = *ov.EnableGarble
+	}
+	if ov.EnableUPX != nil {
+		tb.EnableUPX = *ov.EnableUPX
+	}
+	if ov.EnableOsslsigncode != nil {
+		tb.EnableOsslsigncode = *ov.EnableOsslsigncode
+	}
+	return &tb
+}
+
+/*
+applyOverride adds the args, ldflags and tags of the override to the args from PrepareArgs.
+The package stays the last arg.
+*/
+func (b *Builder) applyOverride(args []string) []string {
+	ov := b.override
+	if ov == nil || len(args) == 0 {
+		return args
+	}
+	flags := append([]string{}, args[:len(args)-1]...)
+	pkg := args[len(args)-1]
+
+	if ov.Ldflags != "" {
+		flags = mergeFlag(flags, "-ldflags", ov.Ldflags, " ")
+	}
+	if len(ov.Tags) > 0 {
+		flags = mergeFlag(flags, "-tags", strings.Join(ov.Tags, ","), ",")
+	}
+	flags = append(flags, ov.Args...)
+	return append(flags, pkg)
+}
+
+/*
+explainOverride says which args are from the overrides of the target.
+*/
+func (b *Builder) explainOverride() (notes []string) {
+	ov := b.override
+	if ov == nil {
+		return nil
+	}
+	if ov.BuildArgs != nil {
+		notes = append(notes, "the build args are replaced by the overrides")
+	}
+	if ov.Ldflags != "" {
+		notes = append(notes, fmt.Sprintf("%q is appended to -ldflags by the overrides", ov.Ldflags))
+	}
+	if len(ov.Tags) > 0 {
+		notes = append(notes, fmt.Sprintf("%q is appended to -tags by the overrides", strings.Join(ov.Tags, ",")))
+	}
+	if len(ov.Args) > 0 {
+		notes = append(notes, fmt.Sprintf("%q are added by the overrides", ov.Args))
+	}
+	return notes
+}
+
+/*
+mergeFlag adds value to the flag in args, like "-tags a" or "-tags=a", or adds the flag if it is not in args.
+go build only takes the last value of a flag.
+*/
+func mergeFlag(args []string, flag, value, sep string) []string {
+	for idx := len(args) - 1; idx >= 0; idx-- {
+		arg := args[idx]
+		switch {
+		case (arg == flag || arg == "-"+flag) && idx < len(args)-1:
+			if args[idx+1] != "" {
+				value = args[idx+1] + sep + value
+			}
+			args[idx+1] = value
+			return args
+		case strings.HasPrefix(arg, flag+"=") || strings.HasPrefix(arg, "-"+flag+"="):
+			name, old, _ := strings.Cut(arg, "=")
+			if old != "" {
+				value = old + sep + value
+			}
+			args[idx] = name + "=" + value
+			return args
+		}
+	}
+	return append(args, flag, value)
+}
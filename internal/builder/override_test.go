@@ -0,0 +1,81 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeFlag(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		flag  string
+		value string
+		sep   string
+		want  []string
+	}{
+		{
+			name: "flag is added",
+			args: []string{"-trimpath"}, flag: "-tags", value: "netgo", sep: ",",
+			want: []string{"-trimpath", "-tags", "netgo"},
+		},
+		{
+			name: "spaced value",
+			args: []string{"-tags", "sqlite", "-v"}, flag: "-tags", value: "netgo", sep: ",",
+			want: []string{"-tags", "sqlite,netgo", "-v"},
+		},
+		{
+			name: "value after =",
+			args: []string{"-ldflags=-s -w"}, flag: "-ldflags", value: "-H windowsgui", sep: " ",
+			want: []string{"-ldflags=-s -w -H windowsgui"},
+		},
+		{
+			name: "double dash",
+			args: []string{"--tags", "a"}, flag: "-tags", value: "b", sep: ",",
+			want: []string{"--tags", "a,b"},
+		},
+		{
+			name: "double dash with =",
+			args: []string{"--ldflags=-s"}, flag: "-ldflags", value: "-w", sep: " ",
+			want: []string{"--ldflags=-s -w"},
+		},
+		{
+			name: "empty value",
+			args: []string{"-tags", "", "-tags="}, flag: "-tags", value: "netgo", sep: ",",
+			want: []string{"-tags", "", "-tags=netgo"},
+		},
+		{
+			name: "the last flag wins",
+			args: []string{"-tags", "a", "-tags", "c"}, flag: "-tags", value: "b", sep: ",",
+			want: []string{"-tags", "a", "-tags", "c,b"},
+		},
+		{
+			name: "similar names are kept",
+			args: []string{"-tagsx", "a", "-tagsx=b"}, flag: "-tags", value: "netgo", sep: ",",
+			want: []string{"-tagsx", "a", "-tagsx=b", "-tags", "netgo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{}, tt.args...)
+			if got := mergeFlag(args, tt.flag, tt.value, tt.sep); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeFlag(%q, %q, %q) = %q, want %q", tt.args, tt.flag, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOverride(t *testing.T) {
+	b := New()
+	b.Overrides = map[string]*TargetOverride{
+		"windows/*":     {Ldflags: "-H windowsgui", Tags: []string{"gui"}},
+		"windows/amd64": {Args: []string{"-race"}},
+	}
+	result := &TargetResult{Target: "windows/amd64", Os: "windows", Arch: "amd64"}
+	args := []string{"go", "build", "-ldflags", "-s -w", "-tags=netgo", "./cmd/app"}
+	got := b.forTarget(result).applyOverride(args)
+	want := []string{"go", "build", "-ldflags", "-s -w -H windowsgui", "-tags=netgo,gui", "-race", "./cmd/app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyOverride() = %q, want %q", got, want)
+	}
+}
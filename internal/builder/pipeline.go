@@ -0,0 +1,216 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+StepArtifact describes the output of a target while it goes through the pipeline.
+*/
+type StepArtifact struct {
+	Target  string // like "linux/arm/v7".
+	Os      string
+	Arch    string
+	Variant string
+	BinDir  string
+	BinName string
+	Archive string // path of the archive, empty before the archive step.
+}
+
+// Binary returns the path of the binary.
+func (a *StepArtifact) Binary() string {
+	return filepath.Join(a.BinDir, a.BinName)
+}
+
+/*
+PostContext is passed to the post processors.
+*/
+type PostContext struct {
+	Step     *PipelineStep
+	WorkDir  string
+	BuildDir string
+	Vars     *BuildVars
+	Env      []string // env of the compiler for the target.
+	Out      io.Writer
+	b        *Builder
+}
+
+/*
+PostProcessor is a step of the pipeline after compiling, like UPX, osslsigncode or archiving.
+
+Process returns the new description of the artifact, like a renamed binary or a new archive.
+The artifact passed in must not be modified.
+*/
+type PostProcessor interface {
+	Process(ctx *PostContext, artifact *StepArtifact) (*StepArtifact, error)
+}
+
+// PostProcessorFunc is a function as a PostProcessor.
+type PostProcessorFunc func(ctx *PostContext, artifact *StepArtifact) (*StepArtifact, error)
+
+func (f PostProcessorFunc) Process(ctx *PostContext, artifact *StepArtifact) (*StepArtifact, error) {
+	return f(ctx, artifact)
+}
+
+var (
+	postProcessors   = map[string]PostProcessor{}
+	postProcessorsMu sync.RWMutex
+)
+
+/*
+RegisterPostProcessor makes a post processor available in the pipeline by the name.
+It panics if the name is registered twice, or p is nil.
+*/
+func RegisterPostProcessor(name string, p PostProcessor) {
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	if p == nil {
+		panic("gber: post processor is nil: " + name)
+	}
+	if _, ok := postProcessors[name]; ok {
+		panic("gber: post processor is registered twice: " + name)
+	}
+	postProcessors[name] = p
+}
+
+func lookupPostProcessor(name string) (PostProcessor, bool) {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	p, ok := postProcessors[name]
+	return p, ok
+}
+
+// PostProcessorNames returns the names of the registered post processors.
+func PostProcessorNames() []string {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	names := make([]string, 0, len(postProcessors))
+	for name := range postProcessors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+PipelineStep is a step of the pipeline in build.json:
+
+	"pipeline": [
+	    {"step": "upx", "targets": ["linux/*"]},
+	    {"step": "osslsigncode"},
+	    {"step": "archive"}
+	]
+
+Targets are Os/Arch or glob patterns, all targets if empty.
+Options are for custom post processors.
+*/
+type PipelineStep struct {
+	Step    string         `json:"step"`
+	Targets []string       `json:"targets,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+/*
+DefaultPipeline is used if there is no pipeline in build.json.
+The built-in steps are still switched by enable_upx, enable_osslsigncode and enable_zip.
+*/
+var DefaultPipeline = []*PipelineStep{
+	{Step: StepUPX},
+	{Step: StepOsslsigncode},
+	{Step: StepArchive},
+}
+
+func (s *PipelineStep) appliesTo(target string) bool {
+	if len(s.Targets) == 0 {
+		return true
+	}
+	for _, key := range s.Targets {
+		if matchTarget(key, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RegisterPostProcessor(StepUPX, PostProcessorFunc(func(ctx *PostContext, a *StepArtifact) (*StepArtifact, error) {
+		return a, ctx.b.packWithUPX(a)
+	}))
+	RegisterPostProcessor(StepOsslsigncode, PostProcessorFunc(func(ctx *PostContext, a *StepArtifact) (*StepArtifact, error) {
+		return a, ctx.b.signWithOsslsigncode(a)
+	}))
+	RegisterPostProcessor(StepArchive, PostProcessorFunc(func(ctx *PostContext, a *StepArtifact) (*StepArtifact, error) {
+		archivePath, err := ctx.b.Archive(a.Os, a.Arch, a.BinDir, a.BinName)
+		if err != nil || archivePath == "" {
+			return a, err
+		}
+		na := *a
+		na.Archive = archivePath
+		return &na, nil
+	}))
+}
+
+/*
+skipsStep reports whether a built-in step does nothing for the artifact,
+like upx with enable_upx off, or osslsigncode for a linux binary.
+*/
+func (b *Builder) skipsStep(step string, a *StepArtifact) bool {
+	switch step {
+	case StepUPX:
+		return !b.EnableUPX || !upxSupported(a.Os, a.Arch)
+	case StepOsslsigncode:
+		return !b.EnableOsslsigncode || a.Os != gutils.Windows
+	case StepArchive:
+		return !b.EnableZip
+	}
+	return false
+}
+
+/*
+runPipeline runs the steps of the pipeline for the target one by one.
+The step_finished event is only emitted for the steps that ran.
+*/
+func (b *Builder) runPipeline(artifact *StepArtifact, env []string, out io.Writer) (*StepArtifact, error) {
+	pipeline := b.Pipeline
+	if len(pipeline) == 0 {
+		pipeline = DefaultPipeline
+	}
+	for _, step := range pipeline {
+		if step == nil || !step.appliesTo(artifact.Target) {
+			continue
+		}
+		p, ok := lookupPostProcessor(step.Step)
+		if !ok {
+			return nil, newStepError(artifact.Target, step.Step, fmt.Errorf("%w: %s", ErrUnknownStep, step.Step))
+		}
+		ctx := &PostContext{
+			Step:     step,
+			WorkDir:  b.WorkDir,
+			BuildDir: filepath.Join(b.ProjectDir(), "build"),
+			Vars:     b.vars,
+			Env:      env,
+			Out:      out,
+			b:        b,
+		}
+		skipped := b.skipsStep(step.Step, artifact)
+		start := time.Now()
+		next, err := p.Process(ctx, artifact)
+		if err != nil {
+			return nil, newStepError(artifact.Target, step.Step, err)
+		}
+		if !skipped {
+			b.emit(&Event{Type: EventStepFinished, Target: artifact.Target, Step: step.Step, DurationMs: time.Since(start).Milliseconds()})
+		}
+		if next != nil {
+			artifact = next
+		}
+	}
+	return artifact, nil
+}
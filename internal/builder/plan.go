@@ -0,0 +1,310 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+Plan is what Build would do, for `gber build --dry-run`.
+*/
+type Plan struct {
+	Profile   string        `json:"profile"`
+	Variables *BuildVars    `json:"variables"`
+	Before    []*PlanStep   `json:"before,omitempty"` // the before hooks.
+	Targets   []*TargetPlan `json:"targets"`
+	After     []*PlanStep   `json:"after,omitempty"` // the after hooks.
+	Checksums []string      `json:"checksums"`
+	Manifest  string        `json:"manifest"`
+}
+
+/*
+TargetPlan is the build of a target.
+*/
+type TargetPlan struct {
+	Target  string      `json:"target"`
+	Command []string    `json:"command"`
+	Env     []string    `json:"env"` // set by gber, on top of the env of gber itself, the effective value of each key.
+	Binary  string      `json:"binary"`
+	Archive string      `json:"archive,omitempty"`
+	Steps   []*PlanStep `json:"steps"`           // the hooks and the pipeline, in order.
+	Notes   []string    `json:"notes,omitempty"` // why the build args are merged, added or rewritten, and the missing tools.
+	missing []string    // the notes of the missing tools, always printed.
+}
+
+/*
+PlanStep is a hook or a step of the pipeline.
+*/
+type PlanStep struct {
+	Step    string   `json:"step"`
+	Command []string `json:"command,omitempty"` // empty if the step runs no command, like archive.
+	Detail  string   `json:"detail,omitempty"`
+}
+
+/*
+Plan resolves the commands, the env and the output paths of all the targets without building anything.
+
+Command substitutions like $(git describe --tags) in the build args are still run, they are part of the command.
+*/
+func (b *Builder) Plan() (plan *Plan, err error) {
+	if err = b.resolveVars(); err != nil {
+		return nil, err
+	}
+	plan = &Plan{Profile: b.Name, Variables: b.vars, Targets: []*TargetPlan{}}
+
+	_, name := b.mainArgs()
+	data := b.nameData("", "", name)
+	if plan.Before, err = hookSteps("before", b.Hooks.Before, data); err != nil {
+		return nil, newStepError("", StepHook, err)
+	}
+	for _, osArch := range b.ArchOSList {
+		result := &TargetResult{Target: osArch}
+		if result.Os, result.Arch, result.Variant, err = ParseTarget(osArch); err != nil {
+			return nil, newStepError(osArch, StepPrepare, err)
+		}
+		tp, err := b.forTarget(result).planTarget(result)
+		if err != nil {
+			return nil, err
+		}
+		plan.Targets = append(plan.Targets, tp)
+	}
+	if plan.After, err = hookSteps("after", b.Hooks.After, data); err != nil {
+		return nil, newStepError("", StepHook, err)
+	}
+
+	buildDir := filepath.Join(b.ProjectDir(), "build")
+	plan.Checksums = []string{filepath.Join(buildDir, b.releaseFileName(ChecksumsFileName))}
+	if b.EnableSHA512 {
+		plan.Checksums = append(plan.Checksums, filepath.Join(buildDir, b.releaseFileName(ChecksumsSHA512FileName)))
+	}
+	plan.Manifest = filepath.Join(buildDir, b.releaseFileName(ManifestFileName))
+	return plan, nil
+}
+
+func hookSteps(kind string, commands []string, data *NameData) (steps []*PlanStep, err error) {
+	for _, command := range commands {
+		args, err := hookArgs(kind, command, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) > 0 {
+			steps = append(steps, &PlanStep{Step: StepHook, Command: args, Detail: kind})
+		}
+	}
+	return steps, nil
+}
+
+/*
+missingTool returns ErrToolNotInstalled in a real build, a dry run notes the tool and goes on.
+*/
+func (b *Builder) missingTool(name string) error {
+	if b.missingTools == nil {
+		return fmt.Errorf("%s: %w", name, ErrToolNotInstalled)
+	}
+	*b.missingTools = append(*b.missingTools, fmt.Sprintf("%s is not installed, the build would fail", name))
+	return nil
+}
+
+/*
+planTarget resolves the build of a target, b is the copy of the target from forTarget.
+*/
+func (b *Builder) planTarget(result *TargetResult) (tp *TargetPlan, err error) {
+	osInfo, archInfo := result.Os, result.Arch
+	b.missingTools = &[]string{}
+	if b.EnableGarble {
+		if _, err := exec.LookPath("garble"); err != nil {
+			b.missingTool("garble")
+		}
+	}
+	args, binDir, binName, notes, err := b.targetCommand(result)
+	if err != nil {
+		return nil, newStepError(result.Target, StepPrepare, err)
+	}
+	env, err := b.buildEnv(osInfo, archInfo)
+	if err != nil {
+		return nil, newStepError(result.Target, StepPrepare, err)
+	}
+	tp = &TargetPlan{
+		Target:  result.Target,
+		Command: args,
+		Env:     effectiveEnv(env),
+		Binary:  filepath.Join(binDir, binName),
+		Notes:   append(append([]string{}, b.argsNotes...), notes...),
+	}
+
+	_, name := b.mainArgs()
+	data := b.nameData(osInfo, archInfo, name)
+	if tp.Steps, err = hookSteps("before_each", b.Hooks.BeforeEach, data); err != nil {
+		return nil, newStepError(result.Target, StepHook, err)
+	}
+
+	artifact := &StepArtifact{
+		Target:  result.Target,
+		Os:      osInfo,
+		Arch:    archInfo,
+		Variant: result.Variant,
+		BinDir:  binDir,
+		BinName: binName,
+	}
+	pipeline := b.Pipeline
+	if len(pipeline) == 0 {
+		pipeline = DefaultPipeline
+	}
+	for _, step := range pipeline {
+		if step == nil || !step.appliesTo(result.Target) {
+			continue
+		}
+		ps, archive, err := b.planStep(step, artifact)
+		if err != nil {
+			return nil, newStepError(result.Target, step.Step, err)
+		}
+		if archive != "" {
+			tp.Archive = archive
+		}
+		tp.Steps = append(tp.Steps, ps)
+	}
+
+	afterEach, err := hookSteps("after_each", b.Hooks.AfterEach, data)
+	if err != nil {
+		return nil, newStepError(result.Target, StepHook, err)
+	}
+	tp.Steps = append(tp.Steps, afterEach...)
+	tp.missing = *b.missingTools
+	tp.Notes = append(tp.Notes, tp.missing...)
+	return tp, nil
+}
+
+/*
+effectiveEnv keeps the last value of each key like the compiler sees it, in the order the keys are first set.
+*/
+func effectiveEnv(env []string) []string {
+	keys, values := []string{}, map[string]string{}
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+		values[k] = e
+	}
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, values[k])
+	}
+	return result
+}
+
+/*
+planStep describes a step of the pipeline, the built-in steps are skipped like in the real build.
+*/
+func (b *Builder) planStep(step *PipelineStep, a *StepArtifact) (ps *PlanStep, archive string, err error) {
+	ps = &PlanStep{Step: step.Step}
+	switch step.Step {
+	case StepUPX:
+		switch {
+		case !b.EnableUPX:
+			ps.Detail = "skipped, enable_upx is false"
+		case !upxSupported(a.Os, a.Arch):
+			ps.Detail = fmt.Sprintf("skipped, not supported for %s/%s", a.Os, a.Arch)
+		default:
+			ps.Command, _ = upxArgs(a.BinDir, a.BinName)
+			ps.Detail = "the packed binary replaces the binary"
+			if !IsUPXInstalled() {
+				b.missingTool("upx")
+			}
+		}
+	case StepOsslsigncode:
+		switch {
+		case !b.EnableOsslsigncode:
+			ps.Detail = "skipped, enable_osslsigncode is false"
+		case a.Os != gutils.Windows:
+			ps.Detail = "skipped, only windows binaries are signed"
+		default:
+			ps.Command, _ = b.osslsigncodeArgs(a.BinDir, a.BinName, "******")
+			ps.Detail = "the signed binary replaces the binary"
+			if !IsOsslsigncodeInstalled() {
+				b.missingTool("osslsigncode")
+			}
+		}
+	case StepArchive:
+		if !b.EnableZip {
+			ps.Detail = "skipped, enable_zip is false"
+			break
+		}
+		format := b.archiveFormat(a.Os)
+		if archive, _, err = b.archivePath(a.Os, a.Arch, a.BinDir, format); err != nil {
+			return nil, "", err
+		}
+		files := []string{a.BinName}
+		for _, f := range b.ArchiveFiles {
+			files = append(files, f.Src)
+		}
+		ps.Detail = fmt.Sprintf("%s of %s to %s", format, strings.Join(files, ", "), archive)
+	default:
+		if _, ok := lookupPostProcessor(step.Step); !ok {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownStep, step.Step)
+		}
+		ps.Detail = "custom post processor"
+	}
+	return ps, archive, nil
+}
+
+/*
+Print writes the plan for humans, explain adds why the build args are added or rewritten.
+*/
+func (p *Plan) Print(w io.Writer, explain bool) {
+	printStep := func(indent string, s *PlanStep) {
+		name := s.Step
+		if s.Step == StepHook {
+			name = s.Detail + " hook"
+		}
+		switch {
+		case len(s.Command) > 0 && s.Step != StepHook && s.Detail != "":
+			fmt.Fprintf(w, "%s%s: %s\n%s    # %s\n", indent, name, utils.JoinShellWords(s.Command), indent, s.Detail)
+		case len(s.Command) > 0:
+			fmt.Fprintf(w, "%s%s: %s\n", indent, name, utils.JoinShellWords(s.Command))
+		default:
+			fmt.Fprintf(w, "%s%s: %s\n", indent, name, s.Detail)
+		}
+	}
+
+	fmt.Fprintf(w, "Profile: %s\n", p.Profile)
+	for _, s := range p.Before {
+		printStep("", s)
+	}
+	for _, tp := range p.Targets {
+		fmt.Fprintf(w, "\n[%s]\n", tp.Target)
+		fmt.Fprintf(w, "  command: %s\n", utils.JoinShellWords(tp.Command))
+		fmt.Fprintln(w, "  env:")
+		for _, e := range tp.Env {
+			fmt.Fprintf(w, "    %s\n", utils.JoinShellWords([]string{e}))
+		}
+		fmt.Fprintf(w, "  binary: %s\n", tp.Binary)
+		for _, s := range tp.Steps {
+			printStep("  ", s)
+		}
+		if explain && len(tp.Notes) > 0 {
+			fmt.Fprintln(w, "  explain:")
+			for _, note := range tp.Notes {
+				fmt.Fprintf(w, "    - %s\n", note)
+			}
+		} else {
+			for _, note := range tp.missing {
+				fmt.Fprintf(w, "  warning: %s\n", note)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+	for _, s := range p.After {
+		printStep("", s)
+	}
+	for _, checksums := range p.Checksums {
+		fmt.Fprintf(w, "checksums: %s\n", checksums)
+	}
+	fmt.Fprintf(w, "manifest: %s\n", p.Manifest)
+}
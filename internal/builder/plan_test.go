@@ -0,0 +1,14 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEffectiveEnv(t *testing.T) {
+	env := []string{"GOOS=linux", "CGO_ENABLED=0", "GOARCH=arm", "GOARM=7", "CGO_ENABLED=1", "GOARM=6", "X=a=b"}
+	want := []string{"GOOS=linux", "CGO_ENABLED=1", "GOARCH=arm", "GOARM=6", "X=a=b"}
+	if got := effectiveEnv(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("effectiveEnv() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	DefaultProfile string = "default"
+)
+
+type profileName struct {
+	Name string `json:"name"`
+}
+
+/*
+Profiles in build.json.
+
+The top level fields are the shared defaults, every profile only needs the fields to override:
+
+	{
+		"arch_os_list": ["linux/amd64", "windows/amd64"],
+		"enable_zip": true,
+		"profiles": [
+			{"name": "server", "build_args": ["./cmd/server"]},
+			{"name": "cli", "build_args": ["./cmd/cli"], "enable_upx": true}
+		]
+	}
+
+A build.json without profiles is a single profile named "default".
+*/
+func (b *Builder) ProfileNames() (names []string, err error) {
+	if len(b.Profiles) == 0 {
+		name := b.Name
+		if name == "" {
+			name = DefaultProfile
+		}
+		return []string{name}, nil
+	}
+	for idx, raw := range b.Profiles {
+		p := &profileName{}
+		if err = json.Unmarshal(raw, p); err != nil {
+			return nil, fmt.Errorf("failed to parse profile %d: %w", idx, err)
+		}
+		if p.Name == "" {
+			return nil, fmt.Errorf("profile %d: %w", idx, ErrProfileNameNeeded)
+		}
+		names = append(names, p.Name)
+	}
+	return
+}
+
+/*
+Profile returns a new Builder with the shared defaults overridden by the profile.
+*/
+func (b *Builder) Profile(name string) (pb *Builder, err error) {
+	names, err := b.ProfileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b.Profiles) == 0 && (name == "" || name == names[0]) {
+		return b.inherit(nil)
+	}
+	for idx, n := range names {
+		if n == name {
+			return b.inherit(b.Profiles[idx])
+		}
+	}
+	return nil, fmt.Errorf("%w: %q, available: %s", ErrProfileNotFound, name, strings.Join(names, ", "))
+}
+
+func (b *Builder) inherit(profile json.RawMessage) (pb *Builder, err error) {
+	shared := *b
+	shared.Profiles = nil
+	// deepcopy
+	data, err := json.Marshal(&shared)
+	if err != nil {
+		return nil, err
+	}
+	pb = New()
+	if err = json.Unmarshal(data, pb); err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if err = json.Unmarshal(profile, pb); err != nil {
+			return nil, fmt.Errorf("failed to parse profile: %w", err)
+		}
+	}
+	if pb.Name == "" {
+		pb.Name = DefaultProfile
+	}
+	pb.KeepGoing = b.KeepGoing
+	pb.Force = b.Force
+	pb.Events = b.Events
+	pb.Out = b.Out
+	pb.cliArgs = b.cliArgs
+	return pb, nil
+}
+
+/*
+SaveBuildArgs writes the build args of a profile to the build config file, the other fields and the key order are kept as they are.
+Without profiles in the file, the top level build args are written.
+*/
+func SaveBuildArgs(confPath, profile string, args []string) error {
+	b := New()
+	if err := b.LoadConfFile(confPath); err != nil {
+		return err
+	}
+	names, err := b.ProfileNames()
+	if err != nil {
+		return err
+	}
+	idx := -1
+	for i, n := range names {
+		if n == profile || (profile == "" && len(b.Profiles) == 0) {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("%w: %q, available: %s", ErrProfileNotFound, profile, strings.Join(names, ", "))
+	}
+
+	data, err := os.ReadFile(confPath)
+	if err != nil {
+		return err
+	}
+	conf := map[string]any{}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+	target := conf
+	if len(b.Profiles) > 0 {
+		profiles, _ := conf["profiles"].([]any)
+		if idx >= len(profiles) {
+			return fmt.Errorf("%w: %q", ErrProfileNotFound, profile)
+		}
+		if target, _ = profiles[idx].(map[string]any); target == nil {
+			return fmt.Errorf("failed to parse profile %q", profile)
+		}
+	}
+	target["build_args"] = args
+
+	newData, err := marshalConf(data, conf)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(confPath, newData, os.ModePerm)
+}
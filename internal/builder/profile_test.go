@@ -0,0 +1,41 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveBuildArgsKeyOrder(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "build.json")
+	data := `{"version": 3, "work_dir": ".", "build_args": ["."], "arch_os_list": ["linux/amd64"],
+	"profiles": [{"name": "cli", "build_args": ["./cmd/cli"], "enable_zip": true}]}`
+	if err := os.WriteFile(confPath, []byte(data), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveBuildArgs(confPath, "cli", []string{"-trimpath", "./cmd/cli"}); err != nil {
+		t.Fatal(err)
+	}
+	newData, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, raw, _ := objectKeys(newData)
+	if want := []string{"version", "work_dir", "build_args", "arch_os_list", "profiles"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %q, want %q", keys, want)
+	}
+	want := `[
+        {
+            "name": "cli",
+            "build_args": [
+                "-trimpath",
+                "./cmd/cli"
+            ],
+            "enable_zip": true
+        }
+    ]`
+	if string(raw["profiles"]) != want {
+		t.Errorf("profiles = %s, want %s", raw["profiles"], want)
+	}
+}
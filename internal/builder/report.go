@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	StatusOK      string = "ok"
+	StatusFailed  string = "failed"
+	StatusSkipped string = "skipped"
+	StatusCached  string = "cached" // built before, the artifacts are from the cache.
+)
+
+/*
+Result of building a single Os/Arch target.
+*/
+type TargetResult struct {
+	Target   string
+	Os       string
+	Arch     string
+	Variant  string // like v7 for linux/arm/v7.
+	Binary   string
+	Archive  string
+	Args     []string // the resolved compiler command.
+	Cached   bool
+	Duration time.Duration
+	Err      error
+}
+
+func (r *TargetResult) Status() string {
+	if r.Err == nil {
+		if r.Cached {
+			return StatusCached
+		}
+		return StatusOK
+	}
+	if errors.Is(r.Err, ErrSkipped) {
+		return StatusSkipped
+	}
+	return StatusFailed
+}
+
+/*
+Report collects the results of all targets of a build.
+*/
+type Report struct {
+	Results   []*TargetResult
+	Checksums []string // paths of the checksum files.
+	Manifest  string   // path of manifest.json.
+	Variables *BuildVars
+}
+
+func (r *Report) Failed() (failed []*TargetResult) {
+	for _, res := range r.Results {
+		if res.Status() == StatusFailed {
+			failed = append(failed, res)
+		}
+	}
+	return
+}
+
+// Err returns nil if no target failed.
+func (r *Report) Err() error {
+	failed := r.Failed()
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0].Err
+	default:
+		return fmt.Errorf("%d targets failed, first error: %w", len(failed), failed[0].Err)
+	}
+}
+
+func (r *Report) PrintSummary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tDURATION\tOUTPUT")
+	for _, res := range r.Results {
+		output := res.Binary
+		if res.Err != nil {
+			output = res.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Target, res.Status(), res.Duration.Round(time.Millisecond), output)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,142 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+SourceDateEpoch returns the time of the last commit in dir.
+
+SOURCE_DATE_EPOCH in the environment takes precedence, see https://reproducible-builds.org/specs/source-date-epoch/.
+*/
+func SourceDateEpoch(dir string) (time.Time, error) {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		value = gitOutput(dir, "log", "-1", "--format=%ct")
+	}
+	if value == "" {
+		return time.Time{}, ErrSourceDateEpoch
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", value, err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+/*
+reproducibleArgs enforces -trimpath and -buildvcs=false, the build path and the vcs stamp are not in the binary.
+*/
+func reproducibleArgs(args []string) []string {
+	newArgs := []string{"-trimpath", "-buildvcs=false"}
+	for _, arg := range args {
+		if name := buildFlagName(arg); name == "trimpath" || name == "buildvcs" {
+			continue
+		}
+		newArgs = append(newArgs, arg)
+	}
+	return newArgs
+}
+
+/*
+ReproducibleMismatch is an artifact that differs between two builds.
+*/
+type ReproducibleMismatch struct {
+	Target string
+	File   string // path relative to the build dir.
+	Sums   [2]string
+}
+
+/*
+VerifyReproducible builds the project twice in separate temp dirs in the reproducible mode,
+and compares the sha256 of the binaries and the archives.
+
+Signing is disabled, the signatures are timestamped.
+*/
+func (b *Builder) VerifyReproducible() (mismatches []*ReproducibleMismatch, err error) {
+	projectDir := b.ProjectDir()
+	if projectDir == "" {
+		return nil, ErrProjectNotFound
+	}
+	relWorkDir, err := filepath.Rel(projectDir, b.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	tempDir, err := os.MkdirTemp("", "gber-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	var (
+		reports   [2]*Report
+		buildDirs [2]string
+	)
+	for idx := range reports {
+		copyDir := filepath.Join(tempDir, strconv.Itoa(idx), filepath.Base(projectDir))
+		utils.PrintInfo(b.out(), "Building in %s...", copyDir)
+		err = utils.CopyDir(projectDir, copyDir, func(relPath string) bool {
+			return relPath == "build"
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy project: %w", err)
+		}
+
+		vb, err := b.inherit(nil)
+		if err != nil {
+			return nil, err
+		}
+		vb.WorkDir = filepath.Join(copyDir, relWorkDir)
+		vb.Reproducible = true
+		vb.EnableOsslsigncode = false
+		vb.KeepGoing = false
+		vb.Force = true
+		report, err := vb.Build()
+		if err != nil {
+			return nil, err
+		}
+		if err = report.Err(); err != nil {
+			return nil, err
+		}
+		reports[idx] = report
+		buildDirs[idx] = filepath.Join(copyDir, "build")
+	}
+
+	// compare compares a file of the two builds by its path relative to the build dir.
+	compare := func(target string, files [2]string) error {
+		m := &ReproducibleMismatch{Target: target}
+		for idx, fPath := range files {
+			rel, err := filepath.Rel(buildDirs[idx], fPath)
+			if err != nil {
+				return err
+			}
+			m.File = filepath.ToSlash(rel)
+			if m.Sums[idx] = gutils.ComputeSum(fPath, "sha256"); m.Sums[idx] == "" {
+				return fmt.Errorf("failed to compute sha256 of %s", fPath)
+			}
+		}
+		if m.Sums[0] != m.Sums[1] {
+			mismatches = append(mismatches, m)
+		}
+		return nil
+	}
+	for idx, res := range reports[0].Results {
+		other := reports[1].Results[idx]
+		if err = compare(res.Target, [2]string{res.Binary, other.Binary}); err != nil {
+			return nil, err
+		}
+		if res.Archive != "" {
+			if err = compare(res.Target, [2]string{res.Archive, other.Archive}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,42 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReproducibleArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no flags",
+			args: []string{"-o", "bin/app", "."},
+			want: []string{"-trimpath", "-buildvcs=false", "-o", "bin/app", "."},
+		},
+		{
+			name: "user flags are replaced",
+			args: []string{"-trimpath", "-buildvcs=true", "."},
+			want: []string{"-trimpath", "-buildvcs=false", "."},
+		},
+		{
+			name: "double dash flags are replaced",
+			args: []string{"--trimpath", "--buildvcs=auto", "."},
+			want: []string{"-trimpath", "-buildvcs=false", "."},
+		},
+		{
+			name: "similar names are kept",
+			args: []string{"-trimpathx", "-ldflags", "-s -w", "."},
+			want: []string{"-trimpath", "-buildvcs=false", "-trimpathx", "-ldflags", "-s -w", "."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reproducibleArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reproducibleArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
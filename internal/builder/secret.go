@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+Secrets in build.json, like ossl_pfx_password, should be references instead of literal values:
+
+	env:VAR          the value of environment variable VAR.
+	file:/path       the content of a file, relative paths are in the work dir.
+	cmd:command args the stdout of a command, like "cmd:pass show gber/pfx".
+*/
+const (
+	SecretEnvPrefix  string = "env:"
+	SecretFilePrefix string = "file:"
+	SecretCmdPrefix  string = "cmd:"
+
+	// DefaultPfxPasswordRef is stored by the interactive setup.
+	DefaultPfxPasswordRef string = SecretEnvPrefix + "GBER_PFX_PASSWORD"
+)
+
+func IsSecretRef(value string) bool {
+	for _, prefix := range []string{SecretEnvPrefix, SecretFilePrefix, SecretCmdPrefix} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ResolveSecret returns the secret a value refers to. Literal values are returned as they are.
+
+Relative paths of "file:" and the commands of "cmd:" are resolved in workDir.
+*/
+func ResolveSecret(value, workDir string) (secret string, err error) {
+	switch {
+	case strings.HasPrefix(value, SecretEnvPrefix):
+		name := strings.TrimPrefix(value, SecretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SecretFilePrefix):
+		path := strings.TrimPrefix(value, SecretFilePrefix)
+		if !filepath.IsAbs(path) && workDir != "" {
+			path = filepath.Join(workDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(value, SecretCmdPrefix):
+		args, err := utils.SplitShellWords(strings.TrimPrefix(value, SecretCmdPrefix))
+		if err != nil {
+			return "", err
+		}
+		if len(args) == 0 {
+			return "", fmt.Errorf("empty command for secret")
+		}
+		buf, err := gutils.ExecuteSysCommand(true, workDir, args...)
+		if err != nil {
+			return "", fmt.Errorf("failed to run %q for secret: %w", strings.Join(args, " "), err)
+		}
+		return strings.TrimRight(buf.String(), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,31 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecretFile(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "pfx.pass"), []byte("secret\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"file:pfx.pass", "file:" + filepath.Join(workDir, "pfx.pass")} {
+		got, err := ResolveSecret(value, workDir)
+		if err != nil {
+			t.Fatalf("ResolveSecret(%q) error = %v", value, err)
+		}
+		if got != "secret" {
+			t.Errorf("ResolveSecret(%q) = %q, want %q", value, got, "secret")
+		}
+	}
+
+	t.Setenv("GBER_TEST_SECRET", "from env")
+	if got, err := ResolveSecret("env:GBER_TEST_SECRET", workDir); err != nil || got != "from env" {
+		t.Errorf("ResolveSecret(env) = %q, %v", got, err)
+	}
+	if got, _ := ResolveSecret("literal", workDir); got != "literal" {
+		t.Errorf("ResolveSecret(literal) = %q", got)
+	}
+}
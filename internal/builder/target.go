@@ -0,0 +1,179 @@
+package builder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+)
+
+/*
+variantEnvs are the env vars for the sub-architecture variants, like linux/arm/v7 or linux/amd64/v3.
+*/
+var variantEnvs = map[string]string{
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"amd64":    "GOAMD64",
+	"386":      "GO386",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+}
+
+/*
+ParseTarget splits a target like "linux/amd64" or "linux/arm/v7" into Os, Arch and the optional variant.
+*/
+func ParseTarget(target string) (osInfo, archInfo, variant string, err error) {
+	sList := strings.Split(target, "/")
+	if len(sList) < 2 || len(sList) > 3 || sList[0] == "" || sList[1] == "" {
+		return "", "", "", fmt.Errorf("%w: %q, expected os/arch or os/arch/variant", ErrInvalidTarget, target)
+	}
+	osInfo, archInfo = sList[0], sList[1]
+	if len(sList) == 3 {
+		variant = sList[2]
+		if _, ok := variantEnvs[archInfo]; !ok || variant == "" {
+			return "", "", "", fmt.Errorf("%w: %q, %s has no variants", ErrInvalidTarget, target, archInfo)
+		}
+	}
+	return
+}
+
+/*
+variantEnv returns the env var for the variant of arch, like GOARM=7 for arm/v7.
+*/
+func variantEnv(archInfo, variant string) string {
+	name, ok := variantEnvs[archInfo]
+	if !ok || variant == "" {
+		return ""
+	}
+	if archInfo == "arm" {
+		// GOARM takes the version number only.
+		variant = strings.TrimPrefix(variant, "v")
+	}
+	return fmt.Sprintf("%s=%s", name, variant)
+}
+
+/*
+CheckTargets validates the targets with ParseTarget, and against `go tool dist list`.
+*/
+func CheckTargets(targets []string) error {
+	supported := map[string]bool{}
+	for _, t := range utils.GetAllArchOS() {
+		supported[t] = true
+	}
+	if len(supported) == 0 {
+		return ErrGoNotInstalled
+	}
+	for _, target := range targets {
+		osInfo, archInfo, _, err := ParseTarget(target)
+		if err != nil {
+			return err
+		}
+		if !supported[osInfo+"/"+archInfo] {
+			return fmt.Errorf("%w: %q is not in `go tool dist list`", ErrInvalidTarget, target)
+		}
+	}
+	return nil
+}
+
+/*
+SelectTargets selects the targets of a run by patterns like "linux/arm64", "linux/*" or "!linux/mips*".
+
+A glob selects the configured targets it matches, or the supported targets if it matches none of them.
+A target that is not a glob is selected even if it is not configured. Negations remove targets from the selection,
+or from the configured targets if there are only negations. The patterns are validated against `go tool dist list`.
+*/
+func SelectTargets(configured []string, patterns ...string) (selected []string, err error) {
+	supported := utils.GetAllArchOS()
+	if len(supported) == 0 {
+		return nil, ErrGoNotInstalled
+	}
+	isSupported := map[string]bool{}
+	for _, t := range supported {
+		isSupported[t] = true
+	}
+
+	var includes, excludes []string
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		key := strings.TrimPrefix(pattern, "!")
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty pattern %q", ErrInvalidTarget, pattern)
+		}
+		if isGlob(key) {
+			if _, err = path.Match(key, ""); err != nil {
+				return nil, fmt.Errorf("%w: invalid pattern %q", ErrInvalidTarget, pattern)
+			}
+			matched := false
+			for _, t := range supported {
+				if matched = matchTarget(key, t); matched {
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%w: %q matches no target of `go tool dist list`", ErrInvalidTarget, pattern)
+			}
+		} else {
+			osInfo, archInfo, _, err := ParseTarget(key)
+			if err != nil {
+				return nil, err
+			}
+			if !isSupported[osInfo+"/"+archInfo] {
+				return nil, fmt.Errorf("%w: %q is not in `go tool dist list`", ErrInvalidTarget, pattern)
+			}
+		}
+		if negated {
+			excludes = append(excludes, key)
+		} else {
+			includes = append(includes, key)
+		}
+	}
+
+	candidates := configured
+	if len(includes) > 0 {
+		candidates = []string{}
+		for _, key := range includes {
+			if !isGlob(key) {
+				candidates = append(candidates, key)
+				continue
+			}
+			matches := []string{}
+			for _, t := range configured {
+				if matchTarget(key, t) {
+					matches = append(matches, t)
+				}
+			}
+			if len(matches) == 0 {
+				for _, t := range supported {
+					if matchTarget(key, t) {
+						matches = append(matches, t)
+					}
+				}
+			}
+			candidates = append(candidates, matches...)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, t := range candidates {
+		excluded := false
+		for _, key := range excludes {
+			if excluded = matchTarget(key, t); excluded {
+				break
+			}
+		}
+		if !excluded && !seen[t] {
+			seen[t] = true
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w: %s selects nothing", ErrNoTarget, strings.Join(patterns, ","))
+	}
+	return selected, nil
+}
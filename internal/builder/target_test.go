@@ -0,0 +1,92 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectTargets(t *testing.T) {
+	configured := []string{"linux/amd64", "linux/arm64", "linux/arm/v7", "windows/amd64", "darwin/arm64"}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:     "glob narrows the configured targets",
+			patterns: []string{"linux/*"},
+			want:     []string{"linux/amd64", "linux/arm64", "linux/arm/v7"},
+		},
+		{
+			name:     "negation only",
+			patterns: []string{"!linux/*"},
+			want:     []string{"windows/amd64", "darwin/arm64"},
+		},
+		{
+			name:     "glob and negation",
+			patterns: []string{"linux/*", "!linux/arm*"},
+			want:     []string{"linux/amd64"},
+		},
+		{
+			name:     "negation of os/arch removes the variants",
+			patterns: []string{"!linux/arm", "!darwin/*"},
+			want:     []string{"linux/amd64", "linux/arm64", "windows/amd64"},
+		},
+		{
+			name:     "target that is not configured",
+			patterns: []string{"linux/riscv64", "linux/arm/v6"},
+			want:     []string{"linux/riscv64", "linux/arm/v6"},
+		},
+		{
+			name:     "duplicates are removed",
+			patterns: []string{"linux/amd64", "linux/*", "*/amd64"},
+			want:     []string{"linux/amd64", "linux/arm64", "linux/arm/v7", "windows/amd64"},
+		},
+		{name: "empty pattern", patterns: []string{"!"}, wantErr: ErrInvalidTarget},
+		{name: "invalid glob", patterns: []string{"linux/[a"}, wantErr: ErrInvalidTarget},
+		{name: "glob matches nothing", patterns: []string{"plan10/*"}, wantErr: ErrInvalidTarget},
+		{name: "unsupported target", patterns: []string{"linux/z80"}, wantErr: ErrInvalidTarget},
+		{name: "everything excluded", patterns: []string{"!*/*"}, wantErr: ErrNoTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectTargets(configured, tt.patterns...)
+			if errors.Is(err, ErrGoNotInstalled) {
+				t.Skip("go is not installed")
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SelectTargets(%q) error = %v, want %v", tt.patterns, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectTargets(%q) = %q, want %q", tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectTargetsSupported(t *testing.T) {
+	got, err := SelectTargets([]string{"linux/amd64"}, "freebsd/*", "!freebsd/386")
+	if errors.Is(err, ErrGoNotInstalled) {
+		t.Skip("go is not installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 {
+		t.Fatal("no freebsd target is selected")
+	}
+	for _, target := range got {
+		if !strings.HasPrefix(target, "freebsd/") || target == "freebsd/386" {
+			t.Errorf("unexpected target %q", target)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/gvcgo/gobuilder/internal/utils"
 	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
@@ -14,33 +15,46 @@ func IsUPXInstalled() bool {
 	return err == nil
 }
 
-func (b *Builder) PackWithUPX(osInfo, archInfo, binDir, binName string) {
+func upxSupported(osInfo, archInfo string) bool {
+	// UPX cannot pack binaries for MacOS. Segment fault occurrs.
+	return osInfo != gutils.Darwin && (osInfo != gutils.Windows || archInfo == "amd64")
+}
+
+// upxArgs returns the upx command, the packed binary replaces the binary afterwards.
+func upxArgs(binDir, binName string) (args []string, packedBinPath string) {
+	packedBinPath = filepath.Join(binDir, fmt.Sprintf("packed_%s", binName))
+	return []string{"upx", "-9", "-o", packedBinPath, filepath.Join(binDir, binName)}, packedBinPath
+}
+
+func (b *Builder) PackWithUPX(osInfo, archInfo, binDir, binName string) error {
+	return b.packWithUPX(&StepArtifact{Target: osInfo + "/" + archInfo, Os: osInfo, Arch: archInfo, BinDir: binDir, BinName: binName})
+}
+
+func (b *Builder) packWithUPX(a *StepArtifact) error {
+	osInfo, archInfo, binDir, binName := a.Os, a.Arch, a.BinDir, a.BinName
 	if !b.EnableUPX {
-		return
+		return nil
 	}
 
 	if !IsUPXInstalled() {
-		gprint.PrintWarning("upx is not found!")
-		return
+		return fmt.Errorf("upx: %w", ErrToolNotInstalled)
 	}
 
-	// UPX cannot pack binaries for MacOS. Segment fault occurrs.
-	if osInfo == gutils.Darwin || (osInfo == gutils.Windows && archInfo != "amd64") {
-		gprint.PrintWarning("pack with UPX is not supported for %s/%s", osInfo, archInfo)
-		return
+	if !upxSupported(osInfo, archInfo) {
+		utils.PrintWarning(b.out(), "pack with UPX is not supported for %s", a.Target)
+		return nil
 	}
 
-	fmt.Println(gprint.YellowStr("Packing with UPX..."))
+	fmt.Fprintln(b.out(), gprint.YellowStr("Packing with UPX for %s...", a.Target))
 
 	binPath := filepath.Join(binDir, binName)
-	packedBinPath := filepath.Join(binDir, fmt.Sprintf("packed_%s", binName))
-
-	_, err := gutils.ExecuteSysCommand(true, binDir, "upx", "-9", "-o", packedBinPath, binPath)
+	args, packedBinPath := upxArgs(binDir, binName)
+	b.emit(&Event{Type: EventCommand, Target: a.Target, Step: StepUPX, Command: args})
+	_, err := gutils.ExecuteSysCommand(true, binDir, args...)
 	if err != nil {
-		gprint.PrintError("Failed to pack binary: %+v", err)
 		os.RemoveAll(packedBinPath)
-		return
+		return fmt.Errorf("failed to pack binary: %w", err)
 	}
 	os.RemoveAll(binPath)
-	os.Rename(packedBinPath, binPath)
+	return os.Rename(packedBinPath, binPath)
 }
@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gvcgo/gobuilder/internal/utils"
+)
+
+/*
+BuildVars are resolved by gber once for a build, and available in BuildArgs and name templates:
+
+	-ldflags "-X main.GitTag={{.GitTag}} -X main.GitHash={{.Commit}} -X main.BuildDate={{.BuildDate}}"
+
+The variables are {{.GitTag}}, {{.Version}}, {{.Commit}}, {{.ShortCommit}}, {{.Dirty}}, {{.CommitDate}},
+{{.BuildDate}}, {{.Date}} and {{.GoVersion}}, see NameData for the variables of the target.
+The build time is the commit time in the reproducible mode, see SourceDateEpoch.
+
+Values from git are empty outside of a git repository.
+*/
+type BuildVars struct {
+	GitTag      string `json:"git_tag"`
+	Version     string `json:"version"` // GitTag without the "v" prefix, "dev" if there is no tag.
+	Commit      string `json:"commit"`
+	ShortCommit string `json:"short_commit"`
+	Dirty       bool   `json:"dirty"` // uncommitted changes.
+	CommitDate  string `json:"commit_date"`
+	BuildDate   string `json:"build_date"` // like 2024-05-01T08:00:00Z.
+	Date        string `json:"date"`       // day of BuildDate, like 2024-05-01.
+	GoVersion   string `json:"go_version"`
+}
+
+// gitOutput discards the stderr of git, like "fatal: not a git repository" outside of a repository.
+func gitOutput(dir string, args ...string) string {
+	buf := &bytes.Buffer{}
+	if err := utils.ExecuteCommand(dir, nil, buf, io.Discard, append([]string{"git"}, args...)...); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+/*
+GitTag returns the latest tag of the git repository in dir.
+*/
+func GitTag(dir string) string {
+	return gitOutput(dir, "describe", "--tags", "--abbrev=0")
+}
+
+func ResolveBuildVars(dir string) *BuildVars {
+	vars := &BuildVars{
+		GitTag:     GitTag(dir),
+		Commit:     gitOutput(dir, "rev-parse", "HEAD"),
+		CommitDate: gitOutput(dir, "log", "-1", "--format=%cI"),
+		GoVersion:  GoVersion(),
+	}
+	vars.setBuildTime(time.Now())
+	vars.Version = strings.TrimPrefix(vars.GitTag, "v")
+	if vars.GitTag == "" {
+		vars.Version = "dev"
+	}
+	if len(vars.Commit) > 7 {
+		vars.ShortCommit = vars.Commit[:7]
+	}
+	if vars.Commit != "" {
+		vars.Dirty = gitOutput(dir, "status", "--porcelain") != ""
+	}
+	return vars
+}
+
+// setBuildTime sets BuildDate and Date.
+func (v *BuildVars) setBuildTime(t time.Time) {
+	t = t.UTC()
+	v.BuildDate = t.Format(time.RFC3339)
+	v.Date = t.Format("2006-01-02")
+}
+
+func (v *BuildVars) String() string {
+	return fmt.Sprintf(
+		"GitTag=%s Version=%s Commit=%s ShortCommit=%s Dirty=%t CommitDate=%s BuildDate=%s Date=%s GoVersion=%s",
+		v.GitTag, v.Version, v.Commit, v.ShortCommit, v.Dirty, v.CommitDate, v.BuildDate, v.Date, v.GoVersion,
+	)
+}
@@ -0,0 +1,24 @@
+package builder
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveBuildVarsOutsideGit(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	vars := ResolveBuildVars(dir)
+	if vars.GitTag != "" || vars.Commit != "" || vars.ShortCommit != "" || vars.Dirty {
+		t.Errorf("git values outside of a repository: %+v", vars)
+	}
+	if vars.Version != "dev" || vars.Date == "" || !strings.HasPrefix(vars.BuildDate, vars.Date) {
+		t.Errorf("ResolveBuildVars() = %+v", vars)
+	}
+	for _, field := range []string{"ShortCommit=", "Date=" + vars.Date} {
+		if !strings.Contains(vars.String(), field) {
+			t.Errorf("String() = %q, want %q", vars.String(), field)
+		}
+	}
+}
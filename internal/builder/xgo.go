@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/gvcgo/gobuilder/internal/utils"
-	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/goutils/pkgs/gutils"
 )
 
@@ -18,6 +17,9 @@ crazymax/xgo
 ghcr.io/crazy-max/xgo
 */
 
+// defaultXgoImage is shown in a dry run if no xgo image is found.
+const defaultXgoImage = "crazymax/xgo"
+
 func FindGoProxy() (p string) {
 	return os.Getenv("GOPROXY")
 }
@@ -39,18 +41,22 @@ func FindXgoDockerImage() (imgName string) {
 	return
 }
 
-func (b *Builder) UseXGO(osInfo, archInfo, binDir, binName string, oldArgs []string) (newArgs []string) {
+func (b *Builder) UseXGO(osInfo, archInfo, binDir, binName string, oldArgs []string) (newArgs []string, err error) {
 	if !IsXgoInstalled() {
-		gprint.PrintWarning("xgo is not installed.")
-		os.Exit(1)
+		if err = b.missingTool("xgo"); err != nil {
+			return nil, err
+		}
 	}
 	imgName := b.XGoImage
 	if imgName == "" {
 		imgName = FindXgoDockerImage()
 	}
 	if imgName == "" {
-		gprint.PrintWarning("xgo docker image is not found.")
-		os.Exit(1)
+		if b.missingTools == nil {
+			return nil, ErrXgoImageNotFound
+		}
+		imgName = defaultXgoImage
+		*b.missingTools = append(*b.missingTools, fmt.Sprintf("%s, %s is assumed", ErrXgoImageNotFound, imgName))
 	}
 
 	goProxy := FindGoProxy()
@@ -83,6 +89,10 @@ func (b *Builder) UseXGO(osInfo, archInfo, binDir, binName string, oldArgs []str
 	}
 
 	targets := fmt.Sprintf("%s/%s", osInfo, archInfo)
+	if archInfo == "arm" && b.variant != "" {
+		// like linux/arm-7.
+		targets = fmt.Sprintf("%s-%s", targets, strings.TrimPrefix(b.variant, "v"))
+	}
 
 	newArgs = append(newArgs, "xgo", "-race")
 	if b.XGoDeps != "" {
@@ -122,9 +132,7 @@ func (b *Builder) UseXGO(osInfo, archInfo, binDir, binName string, oldArgs []str
 	}
 
 	newArgs = append(newArgs, importDir)
-
-	fmt.Println(newArgs)
-	return
+	return newArgs, nil
 }
 
 func (b *Builder) FixBinaryName(osInfo, archInfo, binDir, binName string) {
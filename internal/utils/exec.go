@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"github.com/gvcgo/goutils/pkgs/gutils"
+)
+
+/*
+ExecuteCommand runs a command with its own environment.
+
+Unlike gutils.ExecuteSysCommand, the environment of the current process is never modified,
+so commands for different targets can run at the same time.
+*/
+func ExecuteCommand(workDir string, env []string, stdout, stderr io.Writer, args ...string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == gutils.Windows {
+		args = append([]string{"/c"}, args...)
+		cmd = exec.Command("cmd", args...)
+	} else {
+		cmd = exec.Command(args[0], args[1:]...)
+	}
+	cmd.Env = env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if workDir != "" {
+		cmd.Dir = workDir
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+/*
+PrefixWriter writes complete lines to w, each line starting with prefix.
+
+Writers sharing the same mutex never interleave their lines.
+*/
+type PrefixWriter struct {
+	prefix string
+	w      io.Writer
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func NewPrefixWriter(prefix string, w io.Writer, mu *sync.Mutex) *PrefixWriter {
+	if mu == nil {
+		mu = &sync.Mutex{}
+	}
+	return &PrefixWriter{prefix: prefix, w: w, mu: mu}
+}
+
+func (p *PrefixWriter) Write(data []byte) (n int, err error) {
+	n, _ = p.buf.Write(data)
+	for {
+		idx := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if idx < 0 {
+			return n, nil
+		}
+		line := p.buf.Next(idx + 1)
+		if err = p.writeLine(line); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Flush writes out the last line if it does not end with a newline.
+func (p *PrefixWriter) Flush() error {
+	if p.buf.Len() == 0 {
+		return nil
+	}
+	line := append(p.buf.Next(p.buf.Len()), '\n')
+	return p.writeLine(line)
+}
+
+func (p *PrefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	return err
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+)
+
+/*
+PrintInfo and the others look like the ones of gprint, but write to w instead of os.Stdout,
+so that `gber build --output json` keeps stdout for the events.
+*/
+func PrintInfo(w io.Writer, format string, v ...any) {
+	printHint(w, gprint.HintInfo, "#40E0D0", format, v...)
+}
+
+func PrintSuccess(w io.Writer, format string, v ...any) {
+	printHint(w, gprint.HintSuccess, "#32CD32", format, v...)
+}
+
+func PrintWarning(w io.Writer, format string, v ...any) {
+	printHint(w, gprint.HintWarning, "#FFFF00", format, v...)
+}
+
+func PrintError(w io.Writer, format string, v ...any) {
+	printHint(w, gprint.HintError, "#FF6347", format, v...)
+}
+
+func printHint(w io.Writer, hint, color, format string, v ...any) {
+	h := lipgloss.NewStyle().Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color(color)).Render(hint)
+	txt := lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(fmt.Sprintf(format, v...))
+	fmt.Fprintln(w, h+" "+txt)
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+SplitShellWords splits a command line like a POSIX shell does, without any expansion.
+
+Single quotes keep everything literally, double quotes and backslashes escape like in sh.
+*/
+func SplitShellWords(line string) (words []string, err error) {
+	var (
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range line {
+		switch {
+		case escaped:
+			if quote == '"' && c != '"' && c != '\\' && c != '$' && c != '`' {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape in %q", line)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+/*
+JoinShellWords joins words to a command line, SplitShellWords gives the words back.
+*/
+func JoinShellWords(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w != "" && !strings.ContainsAny(w, " \t\n'\"\\$`|&;<>()*?[]#~{}") {
+			quoted = append(quoted, w)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(w, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+/*
+FindSubstitution finds the first "$(...)" in s, nested parentheses and quoted parentheses are skipped.
+
+start is the index of "$", end is the index after the matching ")". start is -1 if there is none.
+*/
+func FindSubstitution(s string) (start, end int, err error) {
+	start = strings.Index(s, "$(")
+	if start < 0 {
+		return -1, -1, nil
+	}
+	var (
+		depth   int
+		quote   byte
+		escaped bool
+	)
+	for i := start + 2; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			escaped = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return start, i + 1, nil
+			}
+			depth--
+		}
+	}
+	return start, -1, fmt.Errorf("unterminated command substitution in %q", s[start:])
+}
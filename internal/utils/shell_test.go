@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", line: "", want: nil},
+		{name: "spaces only", line: " \t\n ", want: nil},
+		{name: "plain words", line: "go build  -o\tbin/app ./cmd", want: []string{"go", "build", "-o", "bin/app", "./cmd"}},
+		{name: "single quotes", line: `-ldflags '-s -w'`, want: []string{"-ldflags", "-s -w"}},
+		{name: "single quotes keep backslashes", line: `'a\"b' '$x'`, want: []string{`a\"b`, "$x"}},
+		{name: "double quotes", line: `-ldflags "-X main.v=1 -s"`, want: []string{"-ldflags", "-X main.v=1 -s"}},
+		{name: "escaped quote in double quotes", line: `"say \"hi\""`, want: []string{`say "hi"`}},
+		{name: "other escapes in double quotes are kept", line: `"a\nb\\c\$d"`, want: []string{`a\nb\c$d`}},
+		{name: "escaped space", line: `a\ b c`, want: []string{"a b", "c"}},
+		{name: "escaped quote", line: `it\'s`, want: []string{"it's"}},
+		{name: "adjacent quotes join", line: `-X="main.v='1 2'"x`, want: []string{"-X=main.v='1 2'x"}},
+		{name: "empty quotes are a word", line: `a '' ""`, want: []string{"a", "", ""}},
+		{name: "unicode", line: `名字 "你 好"`, want: []string{"名字", "你 好"}},
+		{name: "unterminated single quote", line: `'abc`, wantErr: true},
+		{name: "unterminated double quote", line: `a "b c`, wantErr: true},
+		{name: "unterminated escape", line: `abc\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitShellWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitShellWords(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitShellWords(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinShellWords(t *testing.T) {
+	tests := [][]string{
+		{"go", "build", "./cmd"},
+		{"-ldflags", "-s -w"},
+		{"it's", `a"b`, `c\d`, "$HOME", ""},
+		{"*.go", "a;b", "x y\tz"},
+	}
+	for _, words := range tests {
+		line := JoinShellWords(words)
+		got, err := SplitShellWords(line)
+		if err != nil {
+			t.Fatalf("SplitShellWords(%q) error = %v", line, err)
+		}
+		if !reflect.DeepEqual(got, words) {
+			t.Errorf("SplitShellWords(JoinShellWords(%q)) = %q", words, got)
+		}
+	}
+}
+
+func TestFindSubstitution(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantStart int
+		wantEnd   int
+		wantErr   bool
+	}{
+		{name: "none", s: "-X main.v=1", wantStart: -1, wantEnd: -1},
+		{name: "dollar without paren", s: "$HOME", wantStart: -1, wantEnd: -1},
+		{name: "simple", s: "v=$(git describe)", wantStart: 2, wantEnd: 17},
+		{name: "first of two", s: "$(a)-$(b)", wantStart: 0, wantEnd: 4},
+		{name: "nested parens", s: "$(echo $(date) (x))!", wantStart: 0, wantEnd: 19},
+		{name: "quoted paren", s: `$(echo ")" ')')`, wantStart: 0, wantEnd: 15},
+		{name: "escaped paren", s: `$(echo \))`, wantStart: 0, wantEnd: 10},
+		{name: "escaped quote", s: `$(echo \"))`, wantStart: 0, wantEnd: 10},
+		{name: "unterminated", s: "v=$(git describe", wantStart: 2, wantEnd: -1, wantErr: true},
+		{name: "unterminated nested", s: "$(echo $(date)", wantStart: 0, wantEnd: -1, wantErr: true},
+		{name: "unterminated quote", s: `$(echo ")`, wantStart: 0, wantEnd: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := FindSubstitution(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindSubstitution(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("FindSubstitution(%q) = %d, %d, want %d, %d", tt.s, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
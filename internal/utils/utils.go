@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/gvcgo/goutils/pkgs/gutils"
+	"golang.org/x/term"
 )
 
 func FindGoProjectDir(dirName ...string) string {
@@ -26,6 +28,18 @@ func FindGoProjectDir(dirName ...string) string {
 	}
 }
 
+/*
+GetCommonVariants returns the commonly used sub-architecture variants, like linux/arm/v7.
+*/
+func GetCommonVariants() []string {
+	return []string{
+		"linux/arm/v6",
+		"linux/arm/v7",
+		"linux/amd64/v2",
+		"linux/amd64/v3",
+	}
+}
+
 func GetCommanlyUsedArchOS() []string {
 	return []string{
 		"darwin/amd64",
@@ -47,11 +61,21 @@ func isCommanlyUsed(archOS string) bool {
 	return false
 }
 
-func GetOtherArchOS() []string {
+// GetAllArchOS lists the Os/Arch pairs supported by the go compiler.
+func GetAllArchOS() []string {
 	buff, _ := gutils.ExecuteSysCommand(true, "", "go", "tool", "dist", "list")
-	aoList := strings.Split(buff.String(), "\n")
 	r := []string{}
-	for _, v := range aoList {
+	for _, v := range strings.Split(buff.String(), "\n") {
+		if v = strings.TrimSpace(v); v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func GetOtherArchOS() []string {
+	r := []string{}
+	for _, v := range GetAllArchOS() {
 		if isCommanlyUsed(v) {
 			continue
 		}
@@ -63,3 +87,63 @@ func GetOtherArchOS() []string {
 func GetPathSeparator() string {
 	return string([]rune{filepath.Separator})
 }
+
+// IsInteractive tells whether the user can be asked in the terminal.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+/*
+CopyDir copies the dir src to dst, the files and dirs that skip returns true for are not copied.
+Symlinks are copied as symlinks.
+*/
+func CopyDir(src, dst string, skip func(relPath string) bool) error {
+	return filepath.Walk(src, func(fPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fPath)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skip != nil && skip(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(fPath)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(fPath)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+// DirSize returns the total size of the files in dir.
+func DirSize(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,315 @@
+/*
+Package gobuilder builds go binaries for multiple targets, and post-processes them with UPX, osslsigncode and archiving.
+
+It never prompts in the terminal, so it can be embedded into release tooling.
+
+	gb, err := gobuilder.New(
+		gobuilder.WithTargets("linux/amd64", "windows/amd64"),
+		gobuilder.WithBuildArgs("-ldflags", "-s -w", "./cmd/app"),
+		gobuilder.WithZip(true),
+	)
+	if err != nil {
+		return err
+	}
+	result, err := gb.Build()
+*/
+package gobuilder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gvcgo/gobuilder/internal/builder"
+	"github.com/gvcgo/gobuilder/internal/utils"
+)
+
+const (
+	StatusOK      = builder.StatusOK
+	StatusFailed  = builder.StatusFailed
+	StatusSkipped = builder.StatusSkipped
+	StatusCached  = builder.StatusCached
+)
+
+const (
+	EventTargetStarted  = builder.EventTargetStarted
+	EventCommand        = builder.EventCommand
+	EventStepFinished   = builder.EventStepFinished
+	EventArtifact       = builder.EventArtifact
+	EventError          = builder.EventError
+	EventTargetFinished = builder.EventTargetFinished
+	EventSummary        = builder.EventSummary
+)
+
+var (
+	ErrGoNotInstalled    = builder.ErrGoNotInstalled
+	ErrProjectNotFound   = builder.ErrProjectNotFound
+	ErrNoTarget          = builder.ErrNoTarget
+	ErrToolNotInstalled  = builder.ErrToolNotInstalled
+	ErrXgoImageNotFound  = builder.ErrXgoImageNotFound
+	ErrPfxFileNotFound   = builder.ErrPfxFileNotFound
+	ErrPfxPasswordNeeded = builder.ErrPfxPasswordNeeded
+	ErrSkipped           = builder.ErrSkipped
+	ErrProfileNotFound   = builder.ErrProfileNotFound
+	ErrArchiveFormat     = builder.ErrArchiveFormat
+	ErrInvalidTarget     = builder.ErrInvalidTarget
+	ErrUnknownStep       = builder.ErrUnknownStep
+	ErrCGoMode           = builder.ErrCGoMode
+	ErrSourceDateEpoch   = builder.ErrSourceDateEpoch
+	ErrNotReproducible   = builder.ErrNotReproducible
+)
+
+// ArchiveFile is an extra file for the archives, like README.md or LICENSE.
+type ArchiveFile = builder.ArchiveFile
+
+// BuildVars are resolved once for a build, like {{.GitTag}} and {{.Commit}}.
+type BuildVars = builder.BuildVars
+
+// NameData is available in the name templates and BuildArgs, like {{.Name}}_{{.Version}}_{{.Os}}_{{.Arch}}.
+type NameData = builder.NameData
+
+// ReproducibleMismatch is an artifact that differs between two builds.
+type ReproducibleMismatch = builder.ReproducibleMismatch
+
+// TargetOverride changes the build of some targets, like the tags for linux only.
+type TargetOverride = builder.TargetOverride
+
+// Hooks are commands run before and after the build, and before and after each target.
+type Hooks = builder.Hooks
+
+// PipelineStep is a step of the pipeline after compiling, like {Step: "upx", Targets: []string{"linux/*"}}.
+type PipelineStep = builder.PipelineStep
+
+// PostProcessor is a custom step of the pipeline, registered with RegisterPostProcessor.
+type PostProcessor = builder.PostProcessor
+
+// PostProcessorFunc is a function as a PostProcessor.
+type PostProcessorFunc = builder.PostProcessorFunc
+
+// PostContext is passed to the post processors.
+type PostContext = builder.PostContext
+
+// StepArtifact describes the output of a target in the pipeline.
+type StepArtifact = builder.StepArtifact
+
+/*
+RegisterPostProcessor makes a custom step available in the pipeline by the name, like "strip".
+It panics if the name is registered twice.
+*/
+func RegisterPostProcessor(name string, p PostProcessor) {
+	builder.RegisterPostProcessor(name, p)
+}
+
+// Event is a step of the build, like a started target or a finished compile.
+type Event = builder.Event
+
+// SummaryArtifact is a target in the summary event.
+type SummaryArtifact = builder.SummaryArtifact
+
+// EventHandler receives the events, it may be called from several goroutines.
+type EventHandler = builder.EventHandler
+
+// NewJSONEventHandler writes the events to w as newline-delimited JSON.
+func NewJSONEventHandler(w io.Writer) EventHandler {
+	return builder.NewJSONEventHandler(w)
+}
+
+// Plan is what Build would do, see Builder.Plan.
+type Plan = builder.Plan
+
+// TargetPlan is the command, the env and the outputs of a target in the plan.
+type TargetPlan = builder.TargetPlan
+
+// PlanStep is a hook or a step of the pipeline in the plan.
+type PlanStep = builder.PlanStep
+
+// StepError is returned when a step of the build pipeline fails for a target.
+type StepError = builder.StepError
+
+/*
+Artifact produced for a single target.
+*/
+type Artifact struct {
+	Target   string // like "linux/amd64"
+	Os       string
+	Arch     string
+	Variant  string   // like v7 for linux/arm/v7.
+	Binary   string   // path of the binary.
+	Archive  string   // path of the archive, empty if archiving is disabled.
+	Args     []string // the resolved compiler command.
+	Duration time.Duration
+	Status   string
+	Err      error
+}
+
+/*
+Result of a build.
+*/
+type Result struct {
+	Artifacts []*Artifact
+	Checksums []string // paths of checksums.txt, and checksums.sha512.txt if enabled.
+	Manifest  string   // path of manifest.json.
+	Variables *BuildVars
+	report    *builder.Report
+}
+
+// Err returns nil if no target failed.
+func (r *Result) Err() error {
+	return r.report.Err()
+}
+
+// PrintSummary writes a table of all targets to w.
+func (r *Result) PrintSummary(w io.Writer) {
+	r.report.PrintSummary(w)
+}
+
+type Builder struct {
+	b *builder.Builder
+}
+
+func New(opts ...Option) (gb *Builder, err error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	b := builder.New()
+	if o.confFile != "" {
+		if err = b.LoadConfFile(o.confFile); err != nil {
+			return nil, err
+		}
+		if b, err = b.Profile(o.profile); err != nil {
+			return nil, err
+		}
+	}
+	for _, set := range o.setters {
+		set(b)
+	}
+	if len(o.targetFilters) > 0 {
+		if b.ArchOSList, err = builder.SelectTargets(b.ArchOSList, o.targetFilters...); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.Name == "" {
+		b.Name = builder.DefaultProfile
+	}
+	if b.WorkDir == "" {
+		if b.WorkDir, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	if utils.FindGoProjectDir(b.WorkDir) == "" {
+		return nil, ErrProjectNotFound
+	}
+	if len(b.ArchOSList) == 0 {
+		return nil, ErrNoTarget
+	}
+	if !builder.IsValidArchiveFormat(b.ArchiveFormat) {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveFormat, b.ArchiveFormat)
+	}
+	if !builder.IsValidCGoMode(b.CGoMode) {
+		return nil, fmt.Errorf("%w: %s", ErrCGoMode, b.CGoMode)
+	}
+	return &Builder{b: b}, nil
+}
+
+// ProfileNames returns the names of the profiles in a build.json.
+func ProfileNames(confFile string) ([]string, error) {
+	b := builder.New()
+	if err := b.LoadConfFile(confFile); err != nil {
+		return nil, err
+	}
+	return b.ProfileNames()
+}
+
+// Name returns the name of the profile.
+func (gb *Builder) Name() string {
+	return gb.b.Name
+}
+
+// Targets returns the Os/Arch list to build for.
+func (gb *Builder) Targets() []string {
+	return append([]string{}, gb.b.ArchOSList...)
+}
+
+// BuildArgs returns the flags and args for go build, after all the options.
+func (gb *Builder) BuildArgs() []string {
+	return append([]string{}, gb.b.BuildArgs...)
+}
+
+/*
+MergeBuildArgs merges args into the saved build args.
+A flag in args replaces the same flag in saved, the other flags are added. The packages in args replace the saved ones.
+*/
+func MergeBuildArgs(saved, args []string) []string {
+	return builder.MergeBuildArgs(saved, args)
+}
+
+/*
+SaveBuildArgs writes the build args of a profile to the build config file.
+Without profiles in the file, the top level build args are written.
+*/
+func SaveBuildArgs(confFile, profile string, args []string) error {
+	return builder.SaveBuildArgs(confFile, profile, args)
+}
+
+/*
+Build builds all the targets, and writes the checksums and the manifest of the artifacts.
+
+Failures of targets are reported in the result.
+The returned error is for failures before any target is built, with a nil result,
+or for failures of writing the checksums and the manifest, with the result of the targets.
+*/
+func (gb *Builder) Build() (*Result, error) {
+	report, err := gb.b.Build()
+	if report == nil {
+		return nil, err
+	}
+	result := &Result{
+		Checksums: report.Checksums,
+		Manifest:  report.Manifest,
+		Variables: report.Variables,
+		report:    report,
+	}
+	for _, r := range report.Results {
+		result.Artifacts = append(result.Artifacts, &Artifact{
+			Target:   r.Target,
+			Os:       r.Os,
+			Arch:     r.Arch,
+			Variant:  r.Variant,
+			Binary:   r.Binary,
+			Archive:  r.Archive,
+			Args:     r.Args,
+			Duration: r.Duration,
+			Status:   r.Status(),
+			Err:      r.Err,
+		})
+	}
+	return result, err
+}
+
+/*
+VerifyReproducible builds twice in separate temp dirs in the reproducible mode, and returns the artifacts that differ.
+*/
+func (gb *Builder) VerifyReproducible() ([]*ReproducibleMismatch, error) {
+	return gb.b.VerifyReproducible()
+}
+
+/*
+Plan resolves the commands, the env and the output paths of all the targets without building anything.
+*/
+func (gb *Builder) Plan() (*Plan, error) {
+	return gb.b.Plan()
+}
+
+/*
+SelectTargets selects targets from configured by patterns like "linux/arm64", "linux/*" or "!linux/mips*".
+
+A glob selects the configured targets it matches, or the supported targets if it matches none of them.
+Negations remove targets. The patterns are validated against `go tool dist list`.
+*/
+func SelectTargets(configured []string, patterns ...string) ([]string, error) {
+	return builder.SelectTargets(configured, patterns...)
+}
@@ -0,0 +1,306 @@
+package gobuilder
+
+import (
+	"io"
+
+	"github.com/gvcgo/gobuilder/internal/builder"
+)
+
+type options struct {
+	confFile      string
+	profile       string
+	targetFilters []string
+	setters       []func(b *builder.Builder)
+}
+
+type Option func(o *options)
+
+/*
+WithConfigFile loads the options from a build.json file first.
+The other options override the values from the file.
+*/
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.confFile = path
+	}
+}
+
+/*
+WithProfile picks a profile of the build.json given by WithConfigFile.
+It is needed only if the build.json has profiles.
+*/
+func WithProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// WithWorkDir sets the dir where the compiler runs. Default: current working dir.
+func WithWorkDir(dir string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.WorkDir = dir
+		})
+	}
+}
+
+// WithTargets sets the Os/Arch list to build for, like "linux/amd64".
+func WithTargets(targets ...string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.ArchOSList = append([]string{}, targets...)
+		})
+	}
+}
+
+/*
+WithTargetFilter selects the targets of the run by patterns like "linux/arm64", "linux/*" or "!linux/mips*",
+after the other options. See SelectTargets.
+*/
+func WithTargetFilter(patterns ...string) Option {
+	return func(o *options) {
+		o.targetFilters = append(o.targetFilters, patterns...)
+	}
+}
+
+// WithBuildArgs sets the flags and args for go build.
+func WithBuildArgs(args ...string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.ReplaceArgs(args)
+		})
+	}
+}
+
+/*
+WithMergedBuildArgs merges args into the build args from the config file.
+A flag replaces the same flag, like "-ldflags", the packages replace the saved ones. See MergeBuildArgs.
+*/
+func WithMergedBuildArgs(args ...string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.MergeArgs(args)
+		})
+	}
+}
+
+// WithParallel sets the number of targets built at the same time.
+func WithParallel(jobs int) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.MaxParallel = jobs
+		})
+	}
+}
+
+// WithKeepGoing keeps building the other targets after one fails.
+func WithKeepGoing(keepGoing bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.KeepGoing = keepGoing
+		})
+	}
+}
+
+// WithForce builds all the targets without the build cache.
+func WithForce(force bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Force = force
+		})
+	}
+}
+
+// WithEvents sends the events of the build to handler.
+func WithEvents(handler EventHandler) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Events = handler
+		})
+	}
+}
+
+// WithOutput writes the messages and the compiler output to w instead of os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Out = w
+		})
+	}
+}
+
+// WithGarble obfuscates the binaries with garble.
+func WithGarble(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.EnableGarble = enable
+		})
+	}
+}
+
+// WithCGoMode enables CGO with a C cross compiler: "native", "zig" or "xgo".
+func WithCGoMode(mode string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.CGoMode = mode
+		})
+	}
+}
+
+// WithXgo enables CGO cross-compilation with xgo. Empty values keep the ones in build.json.
+func WithXgo(image, deps, depsArgs string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.CGoMode = builder.CGoModeXgo
+			if image != "" {
+				b.XGoImage = image
+			}
+			if deps != "" {
+				b.XGoDeps = deps
+			}
+			if depsArgs != "" {
+				b.XGoDepsArgs = depsArgs
+			}
+		})
+	}
+}
+
+// WithUPX packs the binaries with UPX.
+func WithUPX(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.EnableUPX = enable
+		})
+	}
+}
+
+/*
+WithOsslsigncode signs the windows binaries with osslsigncode.
+pfxPassword can be a reference like "env:VAR", "file:/path" or "cmd:command".
+*/
+func WithOsslsigncode(pfxFilePath, pfxPassword, company, website string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.EnableOsslsigncode = true
+			b.OsslPfxFilePath = pfxFilePath
+			b.OsslPfxPassword = pfxPassword
+			b.OsslPfxCompany = company
+			b.OsslPfxWebsite = website
+		})
+	}
+}
+
+/*
+WithArchiveFormat sets the format of the archives: zip(default), tar.gz, tar.xz or auto.
+auto uses zip for windows, and tar.gz for the others.
+*/
+func WithArchiveFormat(format string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.ArchiveFormat = format
+		})
+	}
+}
+
+// WithArchiveFiles adds extra files to the archives, Src is a glob pattern relative to the project dir.
+func WithArchiveFiles(files ...*ArchiveFile) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.ArchiveFiles = append(b.ArchiveFiles, files...)
+		})
+	}
+}
+
+// WithArchiveWrapDir wraps everything in a top level dir <name>_<os>-<arch>/ inside the archives.
+func WithArchiveWrapDir(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.ArchiveWrapDir = enable
+		})
+	}
+}
+
+/*
+WithNameTemplates sets go templates for the names of the binaries, their dirs in build/, and the archives.
+Empty templates keep the defaults. See NameData for the available fields.
+*/
+func WithNameTemplates(binary, dir, archive string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.NameTemplates = builder.NameTemplates{Binary: binary, Dir: dir, Archive: archive}
+		})
+	}
+}
+
+// WithAliases maps GOOS and GOARCH to other names in the name templates, like amd64 to x86_64.
+func WithAliases(osAliases, archAliases map[string]string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.OsAliases = osAliases
+			b.ArchAliases = archAliases
+		})
+	}
+}
+
+// WithSHA512 writes checksums.sha512.txt besides checksums.txt.
+func WithSHA512(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.EnableSHA512 = enable
+		})
+	}
+}
+
+// WithZip zips the binaries.
+func WithZip(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.EnableZip = enable
+		})
+	}
+}
+
+// WithReproducible builds with -trimpath, without the vcs stamp, and with the commit time in the archives.
+func WithReproducible(enable bool) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Reproducible = enable
+		})
+	}
+}
+
+// WithOverrides changes the build of the targets matching the keys, like "windows/*" or "linux/amd64".
+func WithOverrides(overrides map[string]*TargetOverride) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Overrides = overrides
+		})
+	}
+}
+
+// WithEnv sets env vars for the compiler, they override the defaults like CGO_ENABLED=0.
+func WithEnv(env map[string]string) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Env = env
+		})
+	}
+}
+
+// WithHooks runs commands before and after the build, and before and after each target.
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Hooks = hooks
+		})
+	}
+}
+
+// WithPipeline sets the steps after compiling, the built-in steps are "upx", "osslsigncode" and "archive".
+func WithPipeline(steps ...*PipelineStep) Option {
+	return func(o *options) {
+		o.setters = append(o.setters, func(b *builder.Builder) {
+			b.Pipeline = steps
+		})
+	}
+}